@@ -0,0 +1,99 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"vtorosyan.learning/internal/models"
+)
+
+// JSON API (/api/v1)
+
+func (app *application) apiSnippetList(w http.ResponseWriter, r *http.Request) {
+	snippets, err := app.snippets.Latest()
+	if err != nil {
+		app.serverErrorJSON(w, r, err)
+		return
+	}
+
+	if snippets == nil {
+		snippets = []models.Snippet{}
+	}
+
+	for i, snippet := range snippets {
+		if !app.unlocked(r, snippet) {
+			snippets[i].Content = ""
+		}
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"snippets": snippets}, nil)
+	if err != nil {
+		app.serverErrorJSON(w, r, err)
+	}
+}
+
+func (app *application) apiSnippetView(w http.ResponseWriter, r *http.Request) {
+	snippet, err := app.viewableSnippet(r)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.errorJSON(w, r, http.StatusNotFound, "snippet not found")
+		} else {
+			app.serverErrorJSON(w, r, err)
+		}
+		return
+	}
+
+	if !app.unlocked(r, snippet) {
+		app.errorJSON(w, r, http.StatusForbidden, "snippet is password protected")
+		return
+	}
+	if app.viewLimited(r, snippet) {
+		app.errorJSON(w, r, http.StatusForbidden, "snippet has a view limit and can only be viewed in a browser")
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"snippet": snippet}, nil)
+	if err != nil {
+		app.serverErrorJSON(w, r, err)
+	}
+}
+
+func (app *application) apiSnippetCreate(w http.ResponseWriter, r *http.Request) {
+	var input snippetCreateForm
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.errorJSON(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	input.validate(app.expiryPresets...)
+	err = app.validateParent(r, &input)
+	if err != nil {
+		app.serverErrorJSON(w, r, err)
+		return
+	}
+	if !input.Valid() {
+		app.failedValidationJSON(w, r, input.Validator)
+		return
+	}
+
+	id, err := app.snippets.Insert(app.authenticatedUserID(r), input.input())
+	if err != nil {
+		app.serverErrorJSON(w, r, err)
+		return
+	}
+
+	snippet, err := app.snippets.Get(id)
+	if err != nil {
+		app.serverErrorJSON(w, r, err)
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", "/api/v1/snippets/"+snippet.Ref())
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"snippet": snippet}, headers)
+	if err != nil {
+		app.serverErrorJSON(w, r, err)
+	}
+}
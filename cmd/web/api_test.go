@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"vtorosyan.learning/internal/assert"
+	"vtorosyan.learning/internal/models"
+)
+
+func TestAPISnippetView(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	_, err := app.snippets.Insert(0, models.SnippetInput{Title: "An old silent pond", Content: "An old silent pond...", Expires: 7})
+	assert.NilError(t, err)
+
+	code, header, body := ts.get(t, "/api/v1/snippets/1")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, header.Get("Content-Type"), "application/json")
+
+	var got struct {
+		Snippet models.Snippet `json:"snippet"`
+	}
+	assert.NilError(t, json.Unmarshal([]byte(body), &got))
+	assert.Equal(t, got.Snippet.Title, "An old silent pond")
+
+	code, _, body = ts.get(t, "/api/v1/snippets/2")
+	assert.Equal(t, code, http.StatusNotFound)
+	assert.StringContains(t, body, `"error"`)
+
+	code, _, body = ts.get(t, "/api/v1/snippets")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, `"snippets"`)
+}
+
+func TestAPISnippetCreate(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	code, _, _ := ts.do(t, http.MethodPost, "/api/v1/snippets", nil, strings.NewReader(`{}`))
+	assert.Equal(t, code, http.StatusBadRequest)
+
+	ts.login(t, "Alice", "alice@example.com")
+	_, _, body := ts.get(t, "/snippet/create")
+	header := http.Header{}
+	header.Set("Content-Type", "application/json")
+	header.Set("X-CSRF-Token", extractCSRFToken(t, body))
+
+	tests := []struct {
+		name     string
+		body     string
+		wantCode int
+		wantBody string
+	}{
+		{name: "Valid", body: `{"title":"Haiku","content":"Over the wintry forest","expires":7}`, wantCode: http.StatusCreated, wantBody: `"id": 1`},
+		{name: "Never expires", body: `{"title":"Haiku","content":"Over the wintry forest","expires":-1}`, wantCode: http.StatusCreated, wantBody: `"id": 2`},
+		{name: "Expires at", body: `{"title":"Haiku","content":"Over the wintry forest","expires_at":"2001-01-01T00:00:00Z"}`, wantCode: http.StatusUnprocessableEntity, wantBody: `"expires_at": "` + ErrExpiresAtInvalid},
+		{name: "Files", body: `{"title":"Module","files":[{"name":"a/b","content":"x"}],"expires":7}`, wantCode: http.StatusUnprocessableEntity, wantBody: `"files[0].name": "` + ErrFileNameInvalid},
+		{name: "Invalid fields", body: `{"title":"","content":"x","expires":2}`, wantCode: http.StatusUnprocessableEntity, wantBody: `"expires": "` + ErrExpiresInvalid},
+		{name: "Unknown field", body: `{"title":"Haiku","colour":"red"}`, wantCode: http.StatusBadRequest, wantBody: "unknown field"},
+		{name: "Malformed", body: `{"title":`, wantCode: http.StatusBadRequest, wantBody: "badly-formed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.do(t, http.MethodPost, "/api/v1/snippets", header, strings.NewReader(tt.body))
+
+			assert.Equal(t, code, tt.wantCode)
+			assert.StringContains(t, body, tt.wantBody)
+		})
+	}
+
+	code, respHeader, _ := ts.do(t, http.MethodPost, "/api/v1/snippets", header,
+		strings.NewReader(`{"title":"Haiku","content":"Over the wintry forest","visibility":"unlisted","expires":7}`))
+	assert.Equal(t, code, http.StatusCreated)
+	snippet, err := app.snippets.Get(3)
+	assert.NilError(t, err)
+	assert.Equal(t, respHeader.Get("Location"), "/api/v1/snippets/"+snippet.Slug)
+
+	code, _, _ = ts.get(t, respHeader.Get("Location"))
+	assert.Equal(t, code, http.StatusOK)
+}
+
+func TestAPITokenAuthentication(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	ts.login(t, "Alice", "alice@example.com")
+
+	_, _, body := ts.get(t, "/user/tokens")
+	form := url.Values{}
+	form.Add("name", "ci")
+	form.Add("csrf_token", extractCSRFToken(t, body))
+	code, _, body := ts.postForm(t, "/user/tokens/create", form)
+	assert.Equal(t, code, http.StatusCreated)
+
+	token := regexp.MustCompile(models.TokenPrefix + `[A-Z2-7]+`).FindString(body)
+	assert.Equal(t, token != "", true)
+
+	// A fresh client has no session or CSRF cookie, like a script would.
+	client := newTestServer(t, app.routes())
+	create := `{"title":"Haiku","content":"Over the wintry forest","expires":7}`
+
+	header := http.Header{}
+	header.Set("Authorization", "Bearer "+token)
+	code, _, _ = client.do(t, http.MethodPost, "/api/v1/snippets", header, strings.NewReader(create))
+	assert.Equal(t, code, http.StatusCreated)
+
+	header.Set("Authorization", "Bearer "+models.TokenPrefix+"AAAA")
+	code, rsHeader, _ := client.do(t, http.MethodPost, "/api/v1/snippets", header, strings.NewReader(create))
+	assert.Equal(t, code, http.StatusUnauthorized)
+	assert.Equal(t, rsHeader.Get("WWW-Authenticate"), "Bearer")
+
+	// A bearer header must never fall back to the session cookie, as it has
+	// bypassed the CSRF check.
+	code, _, _ = ts.do(t, http.MethodPost, "/api/v1/snippets", header, strings.NewReader(create))
+	assert.Equal(t, code, http.StatusUnauthorized)
+
+	_, _, body = ts.get(t, "/user/tokens")
+	form = url.Values{}
+	form.Add("csrf_token", extractCSRFToken(t, body))
+	code, _, _ = ts.postForm(t, "/user/tokens/revoke/1", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	header.Set("Authorization", "Bearer "+token)
+	code, _, _ = client.do(t, http.MethodPost, "/api/v1/snippets", header, strings.NewReader(create))
+	assert.Equal(t, code, http.StatusUnauthorized)
+}
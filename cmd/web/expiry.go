@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Values of the expires form field which are not a number of days.
+const (
+	// keepExpiry is the expires value the edit form uses to leave a
+	// snippet's expiry time unchanged.
+	keepExpiry = 0
+	// neverExpires keeps the snippet until its owner deletes it.
+	neverExpires = -1
+	// customExpiry expires the snippet at the time in the expires_at field.
+	customExpiry = -2
+)
+
+// maxCustomExpiry is how far ahead a snippet can be set to expire at a
+// specific time. Snippets meant to stay for longer should never expire.
+const maxCustomExpiry = 10 * 365 * 24 * time.Hour
+
+// defaultExpiryPresets are the numbers of days offered when the administrator
+// does not set the -expiry-presets flag.
+var defaultExpiryPresets = []int{365, 7, 1}
+
+// expiresAtLayouts are the formats accepted for a custom expiry time: those
+// sent by a datetime-local input, with or without seconds, and RFC 3339 for
+// the API. The first two have no time zone and are read as UTC.
+var expiresAtLayouts = []string{"2006-01-02T15:04", "2006-01-02T15:04:05", time.RFC3339}
+
+// parseExpiryPresets reads a comma separated list of numbers of days, in the
+// order the forms offer them.
+func parseExpiryPresets(s string) ([]int, error) {
+	var presets []int
+	for _, field := range strings.Split(s, ",") {
+		days, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil || days <= 0 {
+			return nil, fmt.Errorf("invalid expiry preset %q: must be a positive number of days", field)
+		}
+		presets = append(presets, days)
+	}
+	return presets, nil
+}
+
+// formatExpiryPresets writes presets in the form parseExpiryPresets reads.
+func formatExpiryPresets(presets []int) string {
+	fields := make([]string, len(presets))
+	for i, days := range presets {
+		fields[i] = strconv.Itoa(days)
+	}
+	return strings.Join(fields, ",")
+}
+
+// parseExpiresAt reads a custom expiry time in one of expiresAtLayouts.
+func parseExpiresAt(s string) (time.Time, bool) {
+	for _, layout := range expiresAtLayouts {
+		t, err := time.Parse(layout, s)
+		if err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// expiryLabel describes a preset number of days in whole years or weeks when
+// it can.
+func expiryLabel(days int) string {
+	n, unit := days, "Day"
+	switch {
+	case days%365 == 0:
+		n, unit = days/365, "Year"
+	case days%7 == 0:
+		n, unit = days/7, "Week"
+	}
+
+	if n == 1 {
+		return "One " + unit
+	}
+	return fmt.Sprintf("%d %ss", n, unit)
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"testing"
+	"vtorosyan.learning/internal/assert"
+)
+
+func TestParseExpiryPresets(t *testing.T) {
+	presets, err := parseExpiryPresets("365, 30,1")
+	assert.NilError(t, err)
+	assert.Equal(t, len(presets), 3)
+	assert.Equal(t, presets[1], 30)
+
+	presets, err = parseExpiryPresets(formatExpiryPresets(defaultExpiryPresets))
+	assert.NilError(t, err)
+	assert.Equal(t, len(presets), len(defaultExpiryPresets))
+	assert.Equal(t, presets[0], defaultExpiryPresets[0])
+
+	for _, s := range []string{"", "7,,1", "0", "-3", "one"} {
+		_, err = parseExpiryPresets(s)
+		assert.Equal(t, err != nil, true)
+	}
+}
+
+func TestExpiryLabel(t *testing.T) {
+	tests := []struct {
+		days int
+		want string
+	}{
+		{1, "One Day"},
+		{3, "3 Days"},
+		{7, "One Week"},
+		{14, "2 Weeks"},
+		{30, "30 Days"},
+		{365, "One Year"},
+		{730, "2 Years"},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, expiryLabel(tt.days), tt.want)
+	}
+}
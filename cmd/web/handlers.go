@@ -1,41 +1,269 @@
 package main
 
 import (
+	"archive/zip"
+	"bytes"
 	"errors"
 	"fmt"
+	"io"
+	"math"
+	"mime"
 	"net/http"
+	"slices"
 	"strconv"
+	"strings"
+	"time"
+	"vtorosyan.learning/internal/highlight"
 	"vtorosyan.learning/internal/models"
 	"vtorosyan.learning/internal/validator"
 )
 
 const (
-	ErrTitleInvalid   = "title can not be blank"
-	ErrTitleTooLong   = "title should be less than 100 characters"
-	ErrContentInvalid = "content can not be blank"
-	ErrExpiresInvalid = "expire field must equal 1, 7 or 365"
+	ErrTitleInvalid       = "title can not be blank"
+	ErrTitleTooLong       = "title should be less than 100 characters"
+	ErrContentInvalid     = "content can not be blank"
+	ErrExpiresInvalid     = "expiry must be one of the listed options"
+	ErrExpiresAtInvalid   = "expiry must be a date and time within the next 10 years"
+	ErrTagsTooMany        = "a snippet can have at most 5 tags"
+	ErrTagTooLong         = "tags should be less than 30 characters"
+	ErrTagInvalid         = "tags can only contain lowercase letters, digits and single hyphens"
+	ErrLanguageInvalid    = "language must be one of the listed languages"
+	ErrContentTypeInvalid = "content type must be code or markdown"
+	ErrVisibilityInvalid  = "visibility must be public, unlisted or private"
+	ErrPasswordTooShort   = "password must be at least 8 characters"
+	ErrPasswordTooLong    = "password should be at most 72 bytes"
+	ErrMaxViewsInvalid    = "maximum views must be between 1 and 100"
+	ErrCiphertextInvalid  = "encrypted content is not valid ciphertext"
+	ErrEncryptedMarkdown  = "encrypted snippets are shown as plain text and can not be Markdown"
+	ErrFilesTooMany       = "a snippet can have at most 10 files"
+	ErrFileNameTooLong    = "file names should be less than 100 characters"
+	ErrFileNameInvalid    = "file names can not contain slashes"
+	ErrFileNameDuplicate  = "file names must be unique"
+	ErrParentInvalid      = "the snippet being forked is no longer available"
+	ErrCommentBlank       = "comment can not be blank"
+	ErrCommentTooLong     = "comments should be at most 2000 characters"
+	ErrCommentLineInvalid = "line must be a line of the chosen file"
+	ErrReplyInvalid       = "the comment being replied to is no longer available"
 )
 
 type snippetCreateForm struct {
-	Title               string `form:"title"`
-	Content             string `form:"content"`
-	Expires             int    `form:"expires"`
+	Title               string             `form:"title" json:"title"`
+	Content             string             `form:"content" json:"content"`
+	Language            string             `form:"language" json:"language"`
+	ContentType         models.ContentType `form:"content_type" json:"content_type"`
+	Visibility          models.Visibility  `form:"visibility" json:"visibility"`
+	Tags                []string           `form:"tags" json:"tags"`
+	Expires             int                `form:"expires" json:"expires"`
+	ExpiresAt           string             `form:"expires_at" json:"expires_at"`
+	Password            string             `form:"password" json:"password"`
+	BurnAfterReading    bool               `form:"burn" json:"burn_after_reading"`
+	MaxViews            int                `form:"max_views" json:"max_views"`
+	Encrypted           bool               `form:"encrypted" json:"encrypted"`
+	Files               []snippetFileForm  `form:"files" json:"files"`
+	Parent              string             `form:"parent" json:"-"`
+	ParentID            int                `form:"-" json:"parent_id"`
+	validator.Validator `form:"-" json:"-"`
+
+	// expiresAt is ExpiresAt once validated.
+	expiresAt time.Time
+}
+
+// snippetFileForm is one row of the files of a snippet form.
+type snippetFileForm struct {
+	Name     string `form:"name" json:"name"`
+	Language string `form:"language" json:"language"`
+	Content  string `form:"content" json:"content"`
+}
+
+// maxViewLimit is the largest view limit a snippet can be given.
+const maxViewLimit = 100
+
+// validate checks the form fields, recording any problems as field errors.
+// It is shared by the create and edit forms and the JSON API, which permit
+// the expiry presets they are given, neverExpires and customExpiry; the edit
+// form additionally permits keepExpiry. An ExpiresAt without an expires value
+// is a custom expiry too, for API clients. The HTML forms send tags as a single comma
+// or space separated field, so they are normalised with models.ParseTags
+// first. The password is optional; bcrypt only looks at its first 72 bytes,
+// so longer ones are refused rather than silently truncated. Burning after
+// reading is a view limit of one. Encrypted content must already be
+// ciphertext, as the browser encrypts it before sending the form.
+func (f *snippetCreateForm) validate(permittedExpires ...int) {
+	f.CheckField(validator.NotBlank(f.Title), "title", ErrTitleInvalid)
+	f.CheckField(validator.MaxChars(f.Title, 100), "title", ErrTitleTooLong)
+
+	if f.Expires == keepExpiry && f.ExpiresAt != "" {
+		f.Expires = customExpiry
+	}
+	f.CheckField(validator.PermittedValue(f.Expires, slices.Concat(permittedExpires, []int{neverExpires, customExpiry})...),
+		"expires", ErrExpiresInvalid)
+	if f.Expires == customExpiry {
+		expiresAt, ok := parseExpiresAt(f.ExpiresAt)
+		until := time.Until(expiresAt)
+		f.CheckField(ok && until > 0 && until <= maxCustomExpiry, "expires_at", ErrExpiresAtInvalid)
+		f.expiresAt = expiresAt
+	}
+
+	if f.ContentType == "" {
+		f.ContentType = models.ContentCode
+	}
+	f.CheckField(validator.PermittedValue(f.ContentType, models.ContentCode, models.ContentMarkdown), "content_type",
+		ErrContentTypeInvalid)
+
+	if f.Visibility == "" {
+		f.Visibility = models.VisibilityPublic
+	}
+	f.CheckField(validator.PermittedValue(f.Visibility, models.VisibilityPublic, models.VisibilityUnlisted,
+		models.VisibilityPrivate), "visibility", ErrVisibilityInvalid)
+
+	f.Tags = models.ParseTags(strings.Join(f.Tags, ","))
+	f.CheckField(len(f.Tags) <= models.MaxTags, "tags", ErrTagsTooMany)
+	for _, tag := range f.Tags {
+		f.CheckField(validator.MaxChars(tag, models.MaxTagLength), "tags", ErrTagTooLong)
+		f.CheckField(validator.Matches(tag, validator.TagRegex), "tags", ErrTagInvalid)
+	}
+
+	if f.Password != "" {
+		f.CheckField(validator.MinChars(f.Password, 8), "password", ErrPasswordTooShort)
+		f.CheckField(len(f.Password) <= 72, "password", ErrPasswordTooLong)
+	}
+
+	if f.BurnAfterReading {
+		f.MaxViews = 1
+	}
+	f.CheckField(f.MaxViews >= 0 && f.MaxViews <= maxViewLimit, "max_views", ErrMaxViewsInvalid)
+
+	if f.Encrypted {
+		f.CheckField(f.ContentType != models.ContentMarkdown, "content_type", ErrEncryptedMarkdown)
+	}
+
+	f.validateFiles()
+}
+
+// validateFiles checks the files of the form, keying their errors by the
+// names of their fields. Rows left without a name or content are dropped, and
+// a form without any files, like those of API clients sending a single
+// content field, has Content and Language as its only file.
+func (f *snippetCreateForm) validateFiles() {
+	f.Files = slices.DeleteFunc(f.Files, func(file snippetFileForm) bool {
+		return file.Name == "" && file.Content == ""
+	})
+	if len(f.Files) == 0 {
+		f.Files = []snippetFileForm{{Language: f.Language, Content: f.Content}}
+	}
+	f.CheckField(len(f.Files) <= models.MaxFiles, "files", ErrFilesTooMany)
+
+	for i, file := range f.Files {
+		field := fmt.Sprintf("files[%d].", i)
+		f.CheckField(validator.NotBlank(file.Content), field+"content", ErrContentInvalid)
+		f.CheckField(validator.MaxChars(file.Name, 100), field+"name", ErrFileNameTooLong)
+		f.CheckField(!strings.ContainsAny(file.Name, `/\`), field+"name", ErrFileNameInvalid)
+		f.CheckField(file.Name == "" || !slices.ContainsFunc(f.Files[:i], func(other snippetFileForm) bool {
+			return other.Name == file.Name
+		}), field+"name", ErrFileNameDuplicate)
+		f.CheckField(file.Language == "" || validator.PermittedValue(file.Language, highlight.IDs()...),
+			field+"language", ErrLanguageInvalid)
+		if f.Encrypted {
+			f.CheckField(file.Content == "" || validCiphertext(file.Content), field+"content", ErrCiphertextInvalid)
+		}
+	}
+}
+
+// input returns the validated form as the fields the snippet store expects.
+func (f *snippetCreateForm) input() models.SnippetInput {
+	files := make([]models.File, len(f.Files))
+	for i, file := range f.Files {
+		files[i] = models.File{Name: file.Name, Language: file.Language, Content: file.Content}
+	}
+
+	return models.SnippetInput{
+		Title:        f.Title,
+		Files:        files,
+		ContentType:  f.ContentType,
+		Visibility:   f.Visibility,
+		Tags:         f.Tags,
+		Expires:      max(f.Expires, 0),
+		ExpiresAt:    f.expiresAt,
+		NeverExpires: f.Expires == neverExpires,
+		ParentID:     f.ParentID,
+		Password:     f.Password,
+		MaxViews:     f.MaxViews,
+		Encrypted:    f.Encrypted,
+	}
+}
+
+type snippetUnlockForm struct {
+	Password            string `form:"password"`
+	validator.Validator `form:"-"`
+}
+
+// commentCreateForm is a comment on a snippet, about the whole snippet or,
+// when Line is set, about that line of the file numbered File. With a Parent
+// it is a reply to that comment instead.
+type commentCreateForm struct {
+	Body                string `form:"body"`
+	Parent              int    `form:"parent"`
+	File                int    `form:"file"`
+	Line                int    `form:"line"`
 	validator.Validator `form:"-"`
 }
 
+// maxCommentLength is the number of characters a comment can hold.
+const maxCommentLength = 2000
+
+// validate checks the comment against the snippet it is about. A line with no
+// file is in the first one, and a comment on the whole snippet or a reply has
+// neither. The server can not count the lines of encrypted snippets, so their
+// comments can not be about a line.
+func (f *commentCreateForm) validate(snippet models.Snippet) {
+	f.CheckField(validator.NotBlank(f.Body), "body", ErrCommentBlank)
+	f.CheckField(validator.MaxChars(f.Body, maxCommentLength), "body", ErrCommentTooLong)
+
+	if f.Line == 0 || f.Parent != 0 {
+		f.Line = 0
+		f.File = 0
+		return
+	}
+	f.File = max(f.File, 1)
+
+	lines := 0
+	if !snippet.Encrypted && f.File <= len(snippet.Files) {
+		lines = strings.Count(strings.TrimSuffix(snippet.Files[f.File-1].Content, "\n"), "\n") + 1
+	}
+	f.CheckField(f.Line > 0 && f.Line <= lines, "line", ErrCommentLineInvalid)
+}
+
 type userSignupForm struct {
-	Name                string `form:"name""`
+	Name                string `form:"name"`
 	Email               string `form:"email"`
 	Password            string `form:"password"`
 	validator.Validator `form:"-"`
 }
 
+type tokenCreateForm struct {
+	Name                string `form:"name"`
+	validator.Validator `form:"-"`
+}
+
 type userLoginForm struct {
 	Email               string `form:"email"`
 	Password            string `form:"password"`
 	validator.Validator `form:"-"`
 }
 
+// highlightCSS serves the stylesheet for highlighted snippets. It is generated
+// by the highlighter itself, so the classes always match its output.
+func (app *application) highlightCSS(w http.ResponseWriter, r *http.Request) {
+	css, err := highlight.CSS()
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/css; charset=utf-8")
+	w.Write(css)
+}
+
 // Main app (snippets)
 func (app *application) home(w http.ResponseWriter, r *http.Request) {
 	snippets, err := app.snippets.Latest()
@@ -45,21 +273,106 @@ func (app *application) home(w http.ResponseWriter, r *http.Request) {
 		} else {
 			app.serverError(w, r, err)
 		}
+		return
+	}
+
+	mostStarred, err := app.snippets.MostStarred(time.Now().Add(-mostStarredWindow), mostStarredLimit)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
 	}
 
 	tData := app.newTemplateData(r)
 	tData.Snippets = snippets
+	tData.MostStarred = mostStarred
 	app.render(w, r, http.StatusOK, "home.tmpl.html", tData)
 }
 
-func (app *application) snippetView(w http.ResponseWriter, r *http.Request) {
-	id, err := strconv.Atoi(r.PathValue("id"))
-	if err != nil || id < 1 {
+// mostStarredWindow is how far back the stars counted by the most starred
+// listing of the home page go, and mostStarredLimit how many snippets it
+// shows.
+const (
+	mostStarredWindow = 7 * 24 * time.Hour
+	mostStarredLimit  = 10
+)
+
+// snippetsPageSize is the number of snippets per page of the /snippets
+// listing.
+const snippetsPageSize = 20
+
+// snippetList is the paginated listing of every live snippet. The sort query
+// parameter picks the order and the after/before cursors the page.
+func (app *application) snippetList(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	sort := models.SnippetOrder(query.Get("sort"))
+	if sort == "" {
+		sort = models.OrderNewest
+	}
+	if !validator.PermittedValue(sort, models.OrderNewest, models.OrderExpiring) {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	page, err := app.snippets.Page(sort, query.Get("after"), query.Get("before"), snippetsPageSize)
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidCursor) {
+			app.clientError(w, http.StatusBadRequest)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Page = page
+	data.Sort = sort
+	app.render(w, r, http.StatusOK, "snippets.tmpl.html", data)
+}
+
+func (app *application) tagView(w http.ResponseWriter, r *http.Request) {
+	tag := r.PathValue("name")
+	if !validator.MaxChars(tag, models.MaxTagLength) || !validator.Matches(tag, validator.TagRegex) {
 		app.clientError(w, http.StatusNotFound)
 		return
 	}
 
-	snippet, err := app.snippets.Get(id)
+	snippets, err := app.snippets.Tagged(tag)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Tag = tag
+	data.Snippets = snippets
+	app.render(w, r, http.StatusOK, "tag.tmpl.html", data)
+}
+
+// searchResultsLimit caps the number of results on the /search page.
+const searchResultsLimit = 50
+
+func (app *application) search(w http.ResponseWriter, r *http.Request) {
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+
+	var results []models.SearchResult
+	if query != "" {
+		var err error
+		results, err = app.snippets.Search(query, searchResultsLimit)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+	}
+
+	data := app.newTemplateData(r)
+	data.Query = query
+	data.Results = results
+	app.render(w, r, http.StatusOK, "search.tmpl.html", data)
+}
+
+func (app *application) snippetView(w http.ResponseWriter, r *http.Request) {
+	snippet, err := app.viewableSnippet(r)
 	if err != nil {
 		if errors.Is(err, models.ErrNoRecord) {
 			app.clientError(w, http.StatusNotFound)
@@ -69,17 +382,292 @@ func (app *application) snippetView(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	tData := app.newTemplateData(r)
-	tData.Snippet = snippet
+	if !app.unlocked(r, snippet) {
+		data := app.newTemplateData(r)
+		data.Snippet = snippet
+		data.Form = snippetUnlockForm{}
+		app.render(w, r, http.StatusOK, "unlock.tmpl.html", data)
+		return
+	}
+
+	// Viewing a snippet with a view limit uses one up, which only a POST
+	// from the confirmation page does, so that link previews and crawlers
+	// cannot burn it.
+	if app.viewLimited(r, snippet) {
+		data := app.newTemplateData(r)
+		data.Snippet = snippet
+		app.render(w, r, http.StatusOK, "reveal.tmpl.html", data)
+		return
+	}
+
+	tData, err := app.snippetViewData(r, snippet)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	tData.Source = r.URL.Query().Has("source")
 	app.render(w, r, http.StatusOK, "view.tmpl.html", tData)
 }
 
+// snippetViewPost uses up one view of a snippet with a view limit and shows
+// it. The page must not be cached, as the snippet may be gone once it has
+// been shown.
+func (app *application) snippetViewPost(w http.ResponseWriter, r *http.Request) {
+	snippet, err := app.viewableSnippet(r)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.clientError(w, http.StatusNotFound)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	if !app.unlocked(r, snippet) || !app.viewLimited(r, snippet) {
+		http.Redirect(w, r, "/snippet/view/"+snippet.Ref(), http.StatusSeeOther)
+		return
+	}
+
+	snippet, err = app.snippets.View(snippet.ID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.clientError(w, http.StatusNotFound)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+
+	data, err := app.snippetViewData(r, snippet)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	app.render(w, r, http.StatusOK, "view.tmpl.html", data)
+}
+
+// snippetUnlockPost checks the password of a protected snippet and, if it is
+// right, remembers in the session that the snippet is unlocked. Attempts are
+// limited per snippet, whoever makes them, to slow down guessing.
+func (app *application) snippetUnlockPost(w http.ResponseWriter, r *http.Request) {
+	snippet, err := app.viewableSnippet(r)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.clientError(w, http.StatusNotFound)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	viewURL := "/snippet/view/" + snippet.Ref()
+	if app.unlocked(r, snippet) {
+		http.Redirect(w, r, viewURL, http.StatusSeeOther)
+		return
+	}
+
+	var form snippetUnlockForm
+
+	err = app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.Password), "password", "This field cannot be blank")
+
+	data := app.newTemplateData(r)
+	data.Snippet = snippet
+
+	if !form.Valid() {
+		data.Form = form
+		app.render(w, r, http.StatusUnprocessableEntity, "unlock.tmpl.html", data)
+		return
+	}
+
+	allowed, retryAfter := app.unlockLimiter.allow(snippet.ID)
+	if !allowed {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+		form.AddNonFieldError("Too many attempts, please try again later")
+		data.Form = form
+		app.render(w, r, http.StatusTooManyRequests, "unlock.tmpl.html", data)
+		return
+	}
+
+	match, err := snippet.MatchesPassword(form.Password)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if !match {
+		form.AddNonFieldError("Password is incorrect")
+		data.Form = form
+		app.render(w, r, http.StatusUnprocessableEntity, "unlock.tmpl.html", data)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), unlockKey(snippet.ID), time.Now().Add(unlockLifetime).Unix())
+
+	http.Redirect(w, r, viewURL, http.StatusSeeOther)
+}
+
+// snippetRaw serves the content of a snippet as plain text, for curl and
+// friends.
+func (app *application) snippetRaw(w http.ResponseWriter, r *http.Request) {
+	app.serveSnippetContent(w, r, false)
+}
+
+// snippetDownload serves the content of a snippet as a file to save.
+func (app *application) snippetDownload(w http.ResponseWriter, r *http.Request) {
+	app.serveSnippetContent(w, r, true)
+}
+
+// snippetArchive serves every file of a snippet together as a zip archive.
+// The archive is built in memory first, so that a failure can still be
+// answered with an error page.
+func (app *application) snippetArchive(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.readableSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for i, name := range snippetFilenames(snippet) {
+		fw, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: snippet.Updated})
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+
+		_, err = io.WriteString(fw, snippet.Files[i].Content)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+	}
+
+	err := zw.Close()
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Content-Disposition",
+		mime.FormatMediaType("attachment", map[string]string{"filename": snippetBasename(snippet) + ".zip"}))
+
+	http.ServeContent(w, r, "", snippet.Updated, bytes.NewReader(buf.Bytes()))
+}
+
+// snippetHistory lists the revisions of a snippet and shows the line-by-line
+// differences between the two chosen with the from and to query parameters,
+// by default the two most recent ones.
+func (app *application) snippetHistory(w http.ResponseWriter, r *http.Request) {
+	snippet, err := app.viewableSnippet(r)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.clientError(w, http.StatusNotFound)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	// The unlock form and the view confirmation live on the view page.
+	if !app.unlocked(r, snippet) || app.viewLimited(r, snippet) {
+		http.Redirect(w, r, "/snippet/view/"+snippet.Ref(), http.StatusSeeOther)
+		return
+	}
+
+	revisions, err := app.snippets.Revisions(snippet.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Snippet = snippet
+	data.Revisions = revisions
+
+	from, to := 0, 0
+	if len(revisions) > 1 {
+		from, to = revisions[1].Number, revisions[0].Number
+	}
+	if r.URL.Query().Has("from") || r.URL.Query().Has("to") {
+		from, err = strconv.Atoi(r.URL.Query().Get("from"))
+		if err != nil {
+			app.clientError(w, http.StatusBadRequest)
+			return
+		}
+		to, err = strconv.Atoi(r.URL.Query().Get("to"))
+		if err != nil {
+			app.clientError(w, http.StatusBadRequest)
+			return
+		}
+	}
+
+	if from != 0 && to != 0 {
+		fromIdx := slices.IndexFunc(revisions, func(rev models.Revision) bool { return rev.Number == from })
+		toIdx := slices.IndexFunc(revisions, func(rev models.Revision) bool { return rev.Number == to })
+		if fromIdx < 0 || toIdx < 0 {
+			app.clientError(w, http.StatusNotFound)
+			return
+		}
+
+		data.Diff = newSnippetDiff(revisions[fromIdx], revisions[toIdx])
+	}
+
+	app.render(w, r, http.StatusOK, "history.tmpl.html", data)
+}
+
 func (app *application) snippetCreate(w http.ResponseWriter, r *http.Request) {
 	tData := app.newTemplateData(r)
-	tData.Form = snippetCreateForm{Expires: 365}
+	tData.Form = snippetCreateForm{ContentType: models.ContentCode, Visibility: models.VisibilityPublic,
+		Expires: app.expiryPresets[0], Files: []snippetFileForm{{}}}
 	app.render(w, r, http.StatusOK, "create.tmpl.html", tData)
 }
 
+// snippetFork shows the create page filled in from an existing snippet, which
+// the new snippet records as its parent.
+func (app *application) snippetFork(w http.ResponseWriter, r *http.Request) {
+	snippet, err := app.viewableSnippet(r)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.clientError(w, http.StatusNotFound)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	if !app.forkable(r, snippet) {
+		app.sessionManager.Put(r.Context(), "flash", "This snippet can not be forked.")
+		http.Redirect(w, r, "/snippet/view/"+snippet.Ref(), http.StatusSeeOther)
+		return
+	}
+
+	form := snippetCreateForm{
+		Title:       snippet.Title,
+		ContentType: snippet.ContentType,
+		Visibility:  models.VisibilityPublic,
+		Tags:        snippet.Tags,
+		Expires:     app.expiryPresets[0],
+		Parent:      snippet.Ref(),
+		ParentID:    snippet.ID,
+	}
+	for _, file := range snippet.Files {
+		form.Files = append(form.Files, snippetFileForm{Name: file.Name, Language: file.Language, Content: file.Content})
+	}
+
+	data := app.newTemplateData(r)
+	data.Form = form
+	app.render(w, r, http.StatusOK, "create.tmpl.html", data)
+}
+
 func (app *application) snippetCreatePost(w http.ResponseWriter, r *http.Request) {
 	err := r.ParseForm()
 	if err != nil {
@@ -95,10 +683,12 @@ func (app *application) snippetCreatePost(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	snippetForm.CheckField(validator.NotBlank(snippetForm.Title), "title", ErrTitleInvalid)
-	snippetForm.CheckField(validator.MaxChars(snippetForm.Title, 100), "title", ErrTitleTooLong)
-	snippetForm.CheckField(validator.NotBlank(snippetForm.Content), "content", ErrContentInvalid)
-	snippetForm.CheckField(validator.PermittedValue(snippetForm.Expires, 1, 7, 365), "expires", ErrExpiresInvalid)
+	snippetForm.validate(app.expiryPresets...)
+	err = app.validateParent(r, &snippetForm)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
 
 	if !snippetForm.Valid() {
 		data := app.newTemplateData(r)
@@ -107,7 +697,15 @@ func (app *application) snippetCreatePost(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	id, err := app.snippets.Insert(snippetForm.Title, snippetForm.Content, snippetForm.Expires)
+	id, err := app.snippets.Insert(app.authenticatedUserID(r), snippetForm.input())
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	// Unlisted snippets are only reached by their slug, which is the link
+	// their creator will share.
+	snippet, err := app.snippets.Get(id)
 	if err != nil {
 		app.serverError(w, r, err)
 		return
@@ -115,7 +713,269 @@ func (app *application) snippetCreatePost(w http.ResponseWriter, r *http.Request
 
 	app.sessionManager.Put(r.Context(), "flash", "Snippet successfully created!")
 
-	http.Redirect(w, r, fmt.Sprintf("/snippet/view/%d", id), http.StatusSeeOther)
+	http.Redirect(w, r, "/snippet/view/"+snippet.Ref(), http.StatusSeeOther)
+}
+
+func (app *application) snippetEdit(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.ownedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	if !app.editable(w, r, snippet) {
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Snippet = snippet
+	form := snippetCreateForm{
+		Title:       snippet.Title,
+		ContentType: snippet.ContentType,
+		Visibility:  snippet.Visibility,
+		Tags:        snippet.Tags,
+		Expires:     keepExpiry,
+	}
+	for _, file := range snippet.Files {
+		form.Files = append(form.Files, snippetFileForm{Name: file.Name, Language: file.Language, Content: file.Content})
+	}
+	data.Form = form
+	app.render(w, r, http.StatusOK, "edit.tmpl.html", data)
+}
+
+func (app *application) snippetEditPost(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.ownedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	if !app.editable(w, r, snippet) {
+		return
+	}
+
+	var snippetForm snippetCreateForm
+
+	err := app.decodePostForm(r, &snippetForm)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	snippetForm.validate(append([]int{keepExpiry}, app.expiryPresets...)...)
+
+	if !snippetForm.Valid() {
+		data := app.newTemplateData(r)
+		data.Snippet = snippet
+		data.Form = snippetForm
+		app.render(w, r, http.StatusUnprocessableEntity, "edit.tmpl.html", data)
+		return
+	}
+
+	err = app.snippets.Update(snippet.ID, snippet.UserID, snippetForm.input())
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	// Making the snippet unlisted gives it a slug.
+	snippet, err = app.snippets.Get(snippet.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Snippet successfully updated!")
+
+	http.Redirect(w, r, "/snippet/view/"+snippet.Ref(), http.StatusSeeOther)
+}
+
+func (app *application) snippetDeletePost(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.ownedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.snippets.Delete(snippet.ID, snippet.UserID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.clientError(w, http.StatusNotFound)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Snippet deleted.")
+
+	http.Redirect(w, r, "/user/snippets", http.StatusSeeOther)
+}
+
+func (app *application) snippetStarPost(w http.ResponseWriter, r *http.Request) {
+	app.setStar(w, r, true)
+}
+
+func (app *application) snippetUnstarPost(w http.ResponseWriter, r *http.Request) {
+	app.setStar(w, r, false)
+}
+
+// setStar stars or unstars the snippet in the id path value for the current
+// user and sends them back to it. Snippets with a view limit for the user
+// can not be starred, as the starred page would lead to them using up
+// views.
+func (app *application) setStar(w http.ResponseWriter, r *http.Request, star bool) {
+	snippet, err := app.viewableSnippet(r)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.clientError(w, http.StatusNotFound)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	userID := app.authenticatedUserID(r)
+	switch {
+	case !star:
+		err = app.snippets.Unstar(snippet.ID, userID)
+	case app.viewLimited(r, snippet):
+		app.sessionManager.Put(r.Context(), "flash", "Snippets with a view limit can not be starred.")
+	default:
+		err = app.snippets.Star(snippet.ID, userID)
+	}
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	http.Redirect(w, r, "/snippet/view/"+snippet.Ref(), http.StatusSeeOther)
+}
+
+// snippetCommentPost adds a comment to a snippet the current user can see the
+// content of.
+func (app *application) snippetCommentPost(w http.ResponseWriter, r *http.Request) {
+	snippet, err := app.viewableSnippet(r)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.clientError(w, http.StatusNotFound)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	if !app.unlocked(r, snippet) || app.viewLimited(r, snippet) {
+		app.clientError(w, http.StatusForbidden)
+		return
+	}
+
+	var form commentCreateForm
+
+	err = app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.validate(snippet)
+	err = app.validateReply(&form, snippet)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	if !form.Valid() {
+		data, err := app.snippetViewData(r, snippet)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		data.Form = form
+		app.render(w, r, http.StatusUnprocessableEntity, "view.tmpl.html", data)
+		return
+	}
+
+	id, err := app.comments.Insert(snippet.ID, app.authenticatedUserID(r), form.Parent, form.File, form.Line, form.Body)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Comment added.")
+
+	http.Redirect(w, r, fmt.Sprintf("/snippet/view/%s#comment-%d", snippet.Ref(), id), http.StatusSeeOther)
+}
+
+// commentDeletePost deletes a comment, which its author and the owner of the
+// snippet it is on can do.
+func (app *application) commentDeletePost(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id < 1 {
+		app.clientError(w, http.StatusNotFound)
+		return
+	}
+
+	comment, err := app.comments.Get(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.clientError(w, http.StatusNotFound)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	snippet, err := app.snippets.Get(comment.SnippetID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.clientError(w, http.StatusNotFound)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	userID := app.authenticatedUserID(r)
+	if !snippet.VisibleTo(userID, true) {
+		app.clientError(w, http.StatusNotFound)
+		return
+	}
+
+	err = app.comments.Delete(comment.ID, userID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.clientError(w, http.StatusForbidden)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Comment deleted.")
+
+	http.Redirect(w, r, "/snippet/view/"+snippet.Ref(), http.StatusSeeOther)
+}
+
+func (app *application) userStarred(w http.ResponseWriter, r *http.Request) {
+	snippets, err := app.snippets.Starred(app.authenticatedUserID(r))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Snippets = snippets
+	app.render(w, r, http.StatusOK, "starred.tmpl.html", data)
+}
+
+func (app *application) userSnippets(w http.ResponseWriter, r *http.Request) {
+	snippets, err := app.snippets.ForUser(app.authenticatedUserID(r))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Snippets = snippets
+	app.render(w, r, http.StatusOK, "mysnippets.tmpl.html", data)
 }
 
 // Users
@@ -230,3 +1090,79 @@ func (app *application) userLogoutPost(w http.ResponseWriter, r *http.Request) {
 
 	http.Redirect(w, r, "/", http.StatusSeeOther)
 }
+
+// API tokens
+
+func (app *application) userTokens(w http.ResponseWriter, r *http.Request) {
+	tokens, err := app.tokens.ForUser(app.authenticatedUserID(r))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Tokens = tokens
+	data.Form = tokenCreateForm{}
+	app.render(w, r, http.StatusOK, "tokens.tmpl.html", data)
+}
+
+func (app *application) userTokenCreatePost(w http.ResponseWriter, r *http.Request) {
+	var form tokenCreateForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	form.CheckField(validator.NotBlank(form.Name), "name", "This field cannot be blank")
+	form.CheckField(validator.MaxChars(form.Name, 100), "name", "This field cannot be more than 100 characters long")
+
+	status := http.StatusUnprocessableEntity
+	var plaintext string
+
+	if form.Valid() {
+		plaintext, err = app.tokens.New(app.authenticatedUserID(r), form.Name)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		status = http.StatusCreated
+		form = tokenCreateForm{}
+	}
+
+	tokens, err := app.tokens.ForUser(app.authenticatedUserID(r))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	// The plaintext token is rendered straight into the response rather than
+	// going through a redirect and the session, so it is only ever shown once.
+	data := app.newTemplateData(r)
+	data.Tokens = tokens
+	data.NewToken = plaintext
+	data.Form = form
+	app.render(w, r, status, "tokens.tmpl.html", data)
+}
+
+func (app *application) userTokenRevokePost(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id < 1 {
+		app.clientError(w, http.StatusNotFound)
+		return
+	}
+
+	err = app.tokens.Revoke(app.authenticatedUserID(r), id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.clientError(w, http.StatusNotFound)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Token revoked.")
+
+	http.Redirect(w, r, "/user/tokens", http.StatusSeeOther)
+}
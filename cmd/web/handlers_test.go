@@ -0,0 +1,1208 @@
+package main
+
+import (
+	"archive/zip"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+	"vtorosyan.learning/internal/assert"
+	"vtorosyan.learning/internal/models"
+)
+
+func TestSnippetView(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	id, err := app.snippets.Insert(0, models.SnippetInput{Title: "An old silent pond", Content: "An old silent pond...", Expires: 7})
+	assert.NilError(t, err)
+	assert.Equal(t, id, 1)
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody string
+	}{
+		{name: "Valid ID", urlPath: "/snippet/view/1", wantCode: http.StatusOK, wantBody: "An old silent pond..."},
+		{name: "Non-existent ID", urlPath: "/snippet/view/2", wantCode: http.StatusNotFound},
+		{name: "Negative ID", urlPath: "/snippet/view/-1", wantCode: http.StatusNotFound},
+		{name: "Decimal ID", urlPath: "/snippet/view/1.23", wantCode: http.StatusNotFound},
+		{name: "String ID", urlPath: "/snippet/view/foo", wantCode: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.urlPath)
+
+			assert.Equal(t, code, tt.wantCode)
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestSnippetCreatePost(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	code, header, _ := ts.get(t, "/snippet/create")
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, header.Get("Location"), "/user/login")
+
+	ts.login(t, "Alice", "alice@example.com")
+
+	_, _, body := ts.get(t, "/snippet/create")
+	csrfToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name     string
+		title    string
+		content  string
+		expires  string
+		wantCode int
+	}{
+		{name: "Valid submission", title: "Haiku", content: "Over the wintry forest", expires: "7", wantCode: http.StatusSeeOther},
+		{name: "Blank title", title: "", content: "Over the wintry forest", expires: "7", wantCode: http.StatusUnprocessableEntity},
+		{name: "Blank content", title: "Haiku", content: " ", expires: "7", wantCode: http.StatusUnprocessableEntity},
+		{name: "Invalid expires", title: "Haiku", content: "Over the wintry forest", expires: "2", wantCode: http.StatusUnprocessableEntity},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("title", tt.title)
+			form.Add("content", tt.content)
+			form.Add("expires", tt.expires)
+			form.Add("csrf_token", csrfToken)
+
+			code, _, _ := ts.postForm(t, "/snippet/create", form)
+			assert.Equal(t, code, tt.wantCode)
+		})
+	}
+
+	code, _, body = ts.get(t, "/snippet/view/1")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "Over the wintry forest")
+}
+
+func TestUserSnippets(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	ts.login(t, "Alice", "alice@example.com")
+
+	_, err := app.snippets.Insert(1, models.SnippetInput{Title: "Mine", Content: "my content", Expires: 7})
+	assert.NilError(t, err)
+	_, err = app.snippets.Insert(1, models.SnippetInput{Title: "Old news", Content: "expired content", Expires: 0})
+	assert.NilError(t, err)
+	_, err = app.snippets.Insert(0, models.SnippetInput{Title: "Someone else's", Content: "not mine", Expires: 7})
+	assert.NilError(t, err)
+
+	code, _, body := ts.get(t, "/user/snippets")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "Mine")
+	assert.StringContains(t, body, "Old news")
+	assert.Equal(t, strings.Contains(body, "Someone else"), false)
+
+	_, _, body = ts.get(t, "/snippet/view/1")
+	assert.StringContains(t, body, "by Alice")
+}
+
+func TestSnippetEditAndDelete(t *testing.T) {
+	app := newTestApplication(t)
+
+	owner := newTestServer(t, app.routes())
+	owner.login(t, "Alice", "alice@example.com")
+	other := newTestServer(t, app.routes())
+	other.login(t, "Bob", "bob@example.com")
+
+	id, err := app.snippets.Insert(1, models.SnippetInput{Title: "Draft", Content: "first version", Expires: 7})
+	assert.NilError(t, err)
+
+	code, _, body := owner.get(t, "/snippet/view/1")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "/snippet/edit/1")
+
+	_, _, body = other.get(t, "/snippet/view/1")
+	assert.Equal(t, strings.Contains(body, "/snippet/edit/1"), false)
+
+	code, _, _ = other.get(t, "/snippet/edit/1")
+	assert.Equal(t, code, http.StatusForbidden)
+
+	_, _, body = other.get(t, "/")
+	form := url.Values{}
+	form.Add("csrf_token", extractCSRFToken(t, body))
+	code, _, _ = other.postForm(t, "/snippet/delete/1", form)
+	assert.Equal(t, code, http.StatusForbidden)
+
+	code, _, body = owner.get(t, "/snippet/edit/1")
+	assert.Equal(t, code, http.StatusOK)
+	csrfToken := extractCSRFToken(t, body)
+
+	form = url.Values{}
+	form.Add("title", "Final")
+	form.Add("content", "")
+	form.Add("expires", "0")
+	form.Add("csrf_token", csrfToken)
+	code, _, _ = owner.postForm(t, "/snippet/edit/1", form)
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+
+	form.Set("content", "second version")
+	code, _, _ = owner.postForm(t, "/snippet/edit/1", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	snippet, err := app.snippets.Get(id)
+	assert.NilError(t, err)
+	assert.Equal(t, snippet.Title, "Final")
+	assert.Equal(t, snippet.Content, "second version")
+
+	form = url.Values{}
+	form.Add("csrf_token", csrfToken)
+	code, _, _ = owner.postForm(t, "/snippet/delete/1", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	code, _, _ = owner.get(t, "/snippet/view/1")
+	assert.Equal(t, code, http.StatusNotFound)
+}
+
+func TestSnippetHistory(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	id, err := app.snippets.Insert(1, models.SnippetInput{Title: "Config", Content: "port = 80\nhost = a", Expires: 7})
+	assert.NilError(t, err)
+	assert.NilError(t, app.snippets.Update(id, 1, models.SnippetInput{Title: "Config", Content: "port = 8080\nhost = a"}))
+	assert.NilError(t, app.snippets.Update(id, 1, models.SnippetInput{Title: "Config", Content: "port = 8080\nhost = b"}))
+
+	code, _, body := ts.get(t, "/snippet/view/1/history")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "Changes from revision #2 to #3")
+	assert.StringContains(t, body, "<tr class='diff-delete'>")
+	assert.StringContains(t, body, "-host = a")
+	assert.StringContains(t, body, "+host = b")
+
+	code, _, body = ts.get(t, "/snippet/view/1/history?from=1&to=3")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "-port = 80</pre>")
+	assert.StringContains(t, body, "+port = 8080</pre>")
+
+	code, _, _ = ts.get(t, "/snippet/view/1/history?from=1&to=9")
+	assert.Equal(t, code, http.StatusNotFound)
+
+	code, _, _ = ts.get(t, "/snippet/view/1/history?from=x&to=1")
+	assert.Equal(t, code, http.StatusBadRequest)
+
+	// Each file is compared on its own, so a change to a later file shows.
+	files := []models.File{{Name: "main.go", Content: "package main"}, {Name: "go.mod", Content: "module a"}}
+	id, err = app.snippets.Insert(1, models.SnippetInput{Title: "Module", Files: files, Expires: 7})
+	assert.NilError(t, err)
+	files = []models.File{files[0], {Name: "go.mod", Content: "module b"}, {Name: "README", Content: "Read me"}}
+	assert.NilError(t, app.snippets.Update(id, 1, models.SnippetInput{Title: "Module", Files: files}))
+
+	code, _, body = ts.get(t, fmt.Sprintf("/snippet/view/%d/history", id))
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "Changes from revision #1 to #2")
+	assert.StringContains(t, body, "<p>No changes.</p>")
+	assert.StringContains(t, body, "<h3>go.mod</h3>")
+	assert.StringContains(t, body, "-module a</pre>")
+	assert.StringContains(t, body, "+module b</pre>")
+	assert.StringContains(t, body, "<p>File added.</p>")
+	assert.StringContains(t, body, "+Read me</pre>")
+}
+
+func TestSnippetList(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	for i := 1; i <= snippetsPageSize+5; i++ {
+		_, err := app.snippets.Insert(0, models.SnippetInput{Title: fmt.Sprintf("Snippet number %d", i), Content: "content", Expires: 7})
+		assert.NilError(t, err)
+	}
+
+	code, _, body := ts.get(t, "/snippets")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, fmt.Sprintf("Snippet number %d<", snippetsPageSize+5))
+	assert.Equal(t, strings.Contains(body, "Snippet number 5<"), false)
+	assert.Equal(t, strings.Contains(body, "Previous"), false)
+
+	next := regexp.MustCompile(`href='(/snippets\?[^']+after=[^']+)'`).FindStringSubmatch(body)
+	if next == nil {
+		t.Fatal("no link to the next page")
+	}
+
+	code, _, body = ts.get(t, html.UnescapeString(next[1]))
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "Snippet number 5<")
+	assert.StringContains(t, body, "Previous")
+	assert.Equal(t, strings.Contains(body, "Next"), false)
+
+	code, _, _ = ts.get(t, "/snippets?sort=random")
+	assert.Equal(t, code, http.StatusBadRequest)
+
+	code, _, _ = ts.get(t, "/snippets?after=%21%21")
+	assert.Equal(t, code, http.StatusBadRequest)
+}
+
+func TestSearch(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	_, err := app.snippets.Insert(0, models.SnippetInput{Title: "Frog haiku", Content: "A frog jumps <into> the pond", Expires: 7})
+	assert.NilError(t, err)
+	_, err = app.snippets.Insert(0, models.SnippetInput{Title: "Unrelated", Content: "nothing to see", Expires: 7})
+	assert.NilError(t, err)
+
+	code, _, body := ts.get(t, "/search")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, strings.Contains(body, "result"), false)
+
+	code, _, body = ts.get(t, "/search?q=frog+pond")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "1 result for")
+	assert.StringContains(t, body, "<mark>Frog</mark> haiku")
+	assert.StringContains(t, body, "A <mark>frog</mark> jumps &lt;into&gt; the <mark>pond</mark>")
+	assert.Equal(t, strings.Contains(body, "Unrelated"), false)
+
+	code, _, body = ts.get(t, "/search?q=%3Cscript%3E")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "No snippets match <strong>&lt;script&gt;</strong>")
+}
+
+func TestSnippetTags(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	ts.login(t, "Alice", "alice@example.com")
+
+	_, _, body := ts.get(t, "/snippet/create")
+	csrfToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name     string
+		tags     string
+		wantCode int
+	}{
+		{name: "Too many", tags: "a b c d e f", wantCode: http.StatusUnprocessableEntity},
+		{name: "Invalid characters", tags: "c++", wantCode: http.StatusUnprocessableEntity},
+		{name: "Too long", tags: strings.Repeat("x", 31), wantCode: http.StatusUnprocessableEntity},
+		{name: "Valid", tags: "Go, net-http go", wantCode: http.StatusSeeOther},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("title", "Server")
+			form.Add("content", "http.ListenAndServe")
+			form.Add("tags", tt.tags)
+			form.Add("expires", "7")
+			form.Add("csrf_token", csrfToken)
+
+			code, _, _ := ts.postForm(t, "/snippet/create", form)
+			assert.Equal(t, code, tt.wantCode)
+		})
+	}
+
+	snippet, err := app.snippets.Get(1)
+	assert.NilError(t, err)
+	assert.Equal(t, strings.Join(snippet.Tags, ","), "go,net-http")
+
+	_, _, body = ts.get(t, "/snippet/view/1")
+	assert.StringContains(t, body, "<a class='tag' href='/tag/net-http'>net-http</a>")
+
+	_, _, body = ts.get(t, "/")
+	assert.StringContains(t, body, "<a class='tag' href='/tag/go'>go</a>")
+
+	code, _, body := ts.get(t, "/tag/go")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "/snippet/view/1")
+
+	code, _, body = ts.get(t, "/tag/rust")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, strings.Contains(body, "/snippet/view/1"), false)
+
+	code, _, _ = ts.get(t, "/tag/Go")
+	assert.Equal(t, code, http.StatusNotFound)
+}
+
+func TestSnippetHighlighting(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	ts.login(t, "Alice", "alice@example.com")
+
+	_, _, body := ts.get(t, "/snippet/create")
+	assert.StringContains(t, body, "<option value='go' >Go</option>")
+	csrfToken := extractCSRFToken(t, body)
+
+	form := url.Values{}
+	form.Add("title", "Hello")
+	form.Add("content", "package main\n\nfunc main() {}\n")
+	form.Add("language", "cobol")
+	form.Add("expires", "7")
+	form.Add("csrf_token", csrfToken)
+
+	code, _, _ := ts.postForm(t, "/snippet/create", form)
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+
+	form.Set("language", "go")
+	code, _, _ = ts.postForm(t, "/snippet/create", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	_, _, body = ts.get(t, "/snippet/view/1")
+	assert.StringContains(t, body, "Go #1")
+	assert.StringContains(t, body, `<a class="lnlinks" href="#L3">3</a>`)
+	assert.StringContains(t, body, `<span class="kd">func</span>`)
+
+	_, _, body = ts.get(t, "/snippet/edit/1")
+	assert.StringContains(t, body, "<option value='go' selected>Go</option>")
+
+	code, header, body := ts.get(t, "/static/css/highlight.css")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, header.Get("Content-Type"), "text/css; charset=utf-8")
+	assert.StringContains(t, body, ".chroma")
+}
+
+func TestSnippetMarkdown(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	_, err := app.snippets.Insert(0, models.SnippetInput{
+		Title:       "Notes",
+		Content:     "## Setup\n\n<script>alert(1)</script>\n\n| a | b |\n|---|---|\n| 1 | 2 |\n",
+		ContentType: models.ContentMarkdown,
+		Expires:     7,
+	})
+	assert.NilError(t, err)
+
+	_, _, body := ts.get(t, "/snippet/view/1")
+	assert.StringContains(t, body, `<h2 id="md-setup">Setup`)
+	assert.StringContains(t, body, "<td>1</td>")
+	assert.Equal(t, strings.Contains(body, "<script>alert"), false)
+	assert.StringContains(t, body, "/snippet/view/1?source")
+
+	_, _, body = ts.get(t, "/snippet/view/1?source")
+	assert.StringContains(t, body, `<a class="lnlinks" href="#L1">1</a>`)
+	assert.StringContains(t, body, `<span class="nt">script</span>`)
+	assert.Equal(t, strings.Contains(body, "<script>alert"), false)
+	assert.Equal(t, strings.Contains(body, "<td>1</td>"), false)
+
+	ts.login(t, "Alice", "alice@example.com")
+	_, _, body = ts.get(t, "/snippet/create")
+
+	form := url.Values{}
+	form.Add("title", "Notes")
+	form.Add("content", "# Hi")
+	form.Add("content_type", "html")
+	form.Add("expires", "7")
+	form.Add("csrf_token", extractCSRFToken(t, body))
+
+	code, _, _ := ts.postForm(t, "/snippet/create", form)
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+}
+
+func TestSnippetRawAndDownload(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	_, err := app.snippets.Insert(0, models.SnippetInput{
+		Title:    `Hello, "wörld" / ../etc`,
+		Content:  "package main\n\n// <b>not html</b>\n",
+		Language: "go",
+		Expires:  7,
+	})
+	assert.NilError(t, err)
+
+	code, header, body := ts.get(t, "/snippet/raw/1")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, body, "package main\n\n// <b>not html</b>")
+	assert.Equal(t, header.Get("Content-Type"), "text/plain; charset=utf-8")
+	assert.Equal(t, header.Get("X-Content-Type-Options"), "nosniff")
+	assert.Equal(t, header.Get("Content-Disposition"), "")
+
+	etag := header.Get("ETag")
+	lastModified := header.Get("Last-Modified")
+	assert.Equal(t, etag != "", true)
+	assert.Equal(t, lastModified != "", true)
+
+	code, _, body = ts.do(t, http.MethodGet, "/snippet/raw/1", http.Header{"If-None-Match": {etag}}, nil)
+	assert.Equal(t, code, http.StatusNotModified)
+	assert.Equal(t, body, "")
+
+	code, _, _ = ts.do(t, http.MethodGet, "/snippet/raw/1", http.Header{"If-Modified-Since": {lastModified}}, nil)
+	assert.Equal(t, code, http.StatusNotModified)
+
+	code, _, _ = ts.do(t, http.MethodGet, "/snippet/raw/1", http.Header{"If-None-Match": {`"stale"`}}, nil)
+	assert.Equal(t, code, http.StatusOK)
+
+	code, header, body = ts.get(t, "/snippet/download/1")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, header.Get("Content-Disposition"), `attachment; filename=Hello-w-rld-etc.go`)
+	assert.StringContains(t, body, "package main")
+
+	code, _, _ = ts.get(t, "/snippet/raw/2")
+	assert.Equal(t, code, http.StatusNotFound)
+}
+
+func TestSnippetFilename(t *testing.T) {
+	tests := []struct {
+		snippet models.Snippet
+		want    string
+	}{
+		{models.Snippet{ID: 1, Title: "main", Language: "go"}, "main.go"},
+		{models.Snippet{ID: 2, Title: "Notes", ContentType: models.ContentMarkdown}, "Notes.md"},
+		{models.Snippet{ID: 3, Title: "日本語", Content: "just text"}, "snippet-3.txt"},
+		{models.Snippet{ID: 4, Title: "..hidden", Language: "bash"}, "hidden.sh"},
+		{models.Snippet{ID: 5, Title: strings.Repeat("a", 70) + ".b", Language: "c"}, strings.Repeat("a", 64) + ".c"},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, snippetFilename(tt.snippet), tt.want)
+	}
+}
+
+func TestSnippetVisibility(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	ts.login(t, "Alice", "alice@example.com")
+
+	_, _, body := ts.get(t, "/snippet/create")
+	form := url.Values{}
+	form.Add("title", "Secret")
+	form.Add("content", "hunter2")
+	form.Add("visibility", "hidden")
+	form.Add("expires", "7")
+	form.Add("csrf_token", extractCSRFToken(t, body))
+	code, _, body := ts.postForm(t, "/snippet/create", form)
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, body, ErrVisibilityInvalid)
+
+	unlisted, err := app.snippets.Insert(1, models.SnippetInput{Title: "Unlisted", Content: "by link only",
+		Visibility: models.VisibilityUnlisted, Expires: 7})
+	assert.NilError(t, err)
+	private, err := app.snippets.Insert(1, models.SnippetInput{Title: "Private", Content: "mine only",
+		Visibility: models.VisibilityPrivate, Expires: 7})
+	assert.NilError(t, err)
+
+	snippet, err := app.snippets.Get(unlisted)
+	assert.NilError(t, err)
+	slug := snippet.Slug
+	assert.Equal(t, len(slug), 26)
+
+	// The owner sees everything, by id as well as by slug.
+	for _, path := range []string{"/snippet/view/1", "/snippet/view/" + slug, "/snippet/view/2", "/snippet/raw/2"} {
+		code, _, _ := ts.get(t, path)
+		assert.Equal(t, code, http.StatusOK)
+	}
+	_, _, body = ts.get(t, "/user/snippets")
+	assert.StringContains(t, body, "/snippet/view/"+slug)
+
+	visitor := newTestServer(t, app.routes())
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+	}{
+		{name: "Unlisted by slug", urlPath: "/snippet/view/" + slug, wantCode: http.StatusOK},
+		{name: "Unlisted raw by slug", urlPath: "/snippet/raw/" + slug, wantCode: http.StatusOK},
+		{name: "Unlisted history by slug", urlPath: "/snippet/view/" + slug + "/history", wantCode: http.StatusOK},
+		{name: "Unlisted by id", urlPath: fmt.Sprintf("/snippet/view/%d", unlisted), wantCode: http.StatusNotFound},
+		{name: "Private", urlPath: fmt.Sprintf("/snippet/view/%d", private), wantCode: http.StatusNotFound},
+		{name: "Private download", urlPath: fmt.Sprintf("/snippet/download/%d", private), wantCode: http.StatusNotFound},
+		{name: "Private API", urlPath: fmt.Sprintf("/api/v1/snippets/%d", private), wantCode: http.StatusNotFound},
+		{name: "Unknown slug", urlPath: "/snippet/view/aaaaaaaaaaaaaaaaaaaaaaaaaa", wantCode: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, _ := visitor.get(t, tt.urlPath)
+			assert.Equal(t, code, tt.wantCode)
+		})
+	}
+
+	_, _, body = visitor.get(t, "/snippet/view/"+slug)
+	assert.StringContains(t, body, "href='/snippet/raw/"+slug+"'")
+
+	_, _, body = visitor.get(t, "/")
+	assert.Equal(t, strings.Contains(body, "Unlisted"), false)
+	assert.Equal(t, strings.Contains(body, "Private"), false)
+
+	// Creating or editing an unlisted snippet leads to its shareable slug
+	// URL, not to its id.
+	form.Set("title", "Shared")
+	form.Set("visibility", "unlisted")
+	code, header, _ := ts.postForm(t, "/snippet/create", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+	snippet, err = app.snippets.Get(3)
+	assert.NilError(t, err)
+	assert.Equal(t, header.Get("Location"), "/snippet/view/"+snippet.Slug)
+
+	form.Set("title", "Not so private")
+	form.Set("expires", "0")
+	code, header, _ = ts.postForm(t, fmt.Sprintf("/snippet/edit/%d", private), form)
+	assert.Equal(t, code, http.StatusSeeOther)
+	snippet, err = app.snippets.Get(private)
+	assert.NilError(t, err)
+	assert.Equal(t, len(snippet.Slug), 26)
+	assert.Equal(t, header.Get("Location"), "/snippet/view/"+snippet.Slug)
+}
+
+func TestSnippetPassword(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	ts.login(t, "Alice", "alice@example.com")
+
+	_, _, body := ts.get(t, "/snippet/create")
+	form := url.Values{}
+	form.Add("title", "Vendor config")
+	form.Add("content", "api_key = secret")
+	form.Add("password", "short")
+	form.Add("expires", "7")
+	form.Add("csrf_token", extractCSRFToken(t, body))
+	code, _, body := ts.postForm(t, "/snippet/create", form)
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, body, ErrPasswordTooShort)
+
+	form.Set("password", "open sesame")
+	code, _, _ = ts.postForm(t, "/snippet/create", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	// The owner never has to unlock their own snippet.
+	_, _, body = ts.get(t, "/snippet/view/1")
+	assert.StringContains(t, body, "secret")
+
+	visitor := newTestServer(t, app.routes())
+	code, _, body = visitor.get(t, "/snippet/view/1")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "This snippet is protected by a password.")
+	assert.Equal(t, strings.Contains(body, "secret"), false)
+	csrfToken := extractCSRFToken(t, body)
+
+	for _, path := range []string{"/snippet/raw/1", "/snippet/download/1", "/api/v1/snippets/1"} {
+		code, _, _ = visitor.get(t, path)
+		assert.Equal(t, code, http.StatusForbidden)
+	}
+	_, _, body = visitor.get(t, "/api/v1/snippets")
+	assert.Equal(t, strings.Contains(body, "secret"), false)
+
+	unlock := func(password string) (int, http.Header, string) {
+		form := url.Values{}
+		form.Add("password", password)
+		form.Add("csrf_token", csrfToken)
+		return visitor.postForm(t, "/snippet/unlock/1", form)
+	}
+
+	// The unlock form is CSRF protected like every other form.
+	code, _, _ = visitor.do(t, http.MethodPost, "/snippet/unlock/1", nil, nil)
+	assert.Equal(t, code, http.StatusBadRequest)
+
+	for range unlockAttempts - 1 {
+		code, _, body = unlock("guess")
+		assert.Equal(t, code, http.StatusUnprocessableEntity)
+		assert.StringContains(t, body, "Password is incorrect")
+	}
+
+	code, header, _ := unlock("open sesame")
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, header.Get("Location"), "/snippet/view/1")
+
+	_, _, body = visitor.get(t, "/snippet/view/1")
+	assert.StringContains(t, body, "secret")
+	code, _, _ = visitor.get(t, "/snippet/raw/1")
+	assert.Equal(t, code, http.StatusOK)
+
+	// Attempts are counted per snippet, not per visitor.
+	other := newTestServer(t, app.routes())
+	_, _, body = other.get(t, "/snippet/view/1")
+	form = url.Values{}
+	form.Add("password", "open sesame")
+	form.Add("csrf_token", extractCSRFToken(t, body))
+	code, header, _ = other.postForm(t, "/snippet/unlock/1", form)
+	assert.Equal(t, code, http.StatusTooManyRequests)
+	assert.Equal(t, header.Get("Retry-After") != "", true)
+}
+
+func TestSnippetViewLimit(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	ts.login(t, "Alice", "alice@example.com")
+
+	_, _, body := ts.get(t, "/snippet/create")
+	form := url.Values{}
+	form.Add("title", "One time")
+	form.Add("content", "the launch code")
+	form.Add("max_views", "101")
+	form.Add("expires", "7")
+	form.Add("csrf_token", extractCSRFToken(t, body))
+	code, _, body := ts.postForm(t, "/snippet/create", form)
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, body, ErrMaxViewsInvalid)
+
+	form.Set("max_views", "")
+	form.Set("burn", "true")
+	code, _, _ = ts.postForm(t, "/snippet/create", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	// The owner's own views are not counted.
+	for range 2 {
+		_, _, body = ts.get(t, "/snippet/view/1")
+		assert.StringContains(t, body, "the launch code")
+	}
+
+	visitor := newTestServer(t, app.routes())
+	code, _, body = visitor.get(t, "/snippet/view/1")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "This snippet will be destroyed once you have viewed it.")
+	assert.Equal(t, strings.Contains(body, "the launch code"), false)
+	csrfToken := extractCSRFToken(t, body)
+
+	for _, path := range []string{"/snippet/raw/1", "/snippet/download/1", "/api/v1/snippets/1"} {
+		code, _, _ = visitor.get(t, path)
+		assert.Equal(t, code, http.StatusForbidden)
+	}
+
+	form = url.Values{}
+	form.Add("csrf_token", csrfToken)
+	code, header, body := visitor.postForm(t, "/snippet/view/1", form)
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, header.Get("Cache-Control"), "no-store")
+	assert.StringContains(t, body, "the launch code")
+	assert.StringContains(t, body, "This snippet has now been destroyed.")
+
+	code, _, _ = visitor.postForm(t, "/snippet/view/1", form)
+	assert.Equal(t, code, http.StatusNotFound)
+	code, _, _ = ts.get(t, "/snippet/view/1")
+	assert.Equal(t, code, http.StatusNotFound)
+}
+
+func TestSnippetEncrypted(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	ts.login(t, "Alice", "alice@example.com")
+
+	// Produced by main.js.
+	ciphertext := "v1.cQ7jdSvR8af4/w247MzgtOH1KWY4zhrCANQ2AKNe3jJ8c3Icp5rw/5nEmki7D62e"
+
+	_, _, body := ts.get(t, "/snippet/create")
+	csrfToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name        string
+		content     string
+		contentType string
+		wantCode    int
+		wantBody    string
+	}{
+		{name: "Plain text", content: "hunter2", contentType: "code", wantCode: http.StatusUnprocessableEntity, wantBody: ErrCiphertextInvalid},
+		{name: "Too short", content: "v1.AAAA", contentType: "code", wantCode: http.StatusUnprocessableEntity, wantBody: ErrCiphertextInvalid},
+		{name: "Markdown", content: ciphertext, contentType: "markdown", wantCode: http.StatusUnprocessableEntity, wantBody: ErrEncryptedMarkdown},
+		{name: "Valid", content: ciphertext, contentType: "code", wantCode: http.StatusSeeOther},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("title", "Secret")
+			form.Add("content", tt.content)
+			form.Add("content_type", tt.contentType)
+			form.Add("encrypted", "true")
+			form.Add("expires", "7")
+			form.Add("csrf_token", csrfToken)
+
+			code, _, body := ts.postForm(t, "/snippet/create", form)
+			assert.Equal(t, code, tt.wantCode)
+			assert.StringContains(t, body, tt.wantBody)
+		})
+	}
+
+	_, _, body = ts.get(t, "/snippet/view/1")
+	assert.StringContains(t, body, "<pre class='encrypted' data-ciphertext='"+ciphertext+"'>")
+	assert.Equal(t, strings.Contains(body, "/snippet/edit/1"), false)
+
+	code, header, _ := ts.get(t, "/snippet/edit/1")
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, header.Get("Location"), "/snippet/view/1")
+
+	snippets, err := app.snippets.Search("secret", 10)
+	assert.NilError(t, err)
+	assert.Equal(t, len(snippets), 0)
+}
+
+func TestSnippetExpiry(t *testing.T) {
+	app := newTestApplication(t)
+	app.expiryPresets = []int{30, 1}
+	ts := newTestServer(t, app.routes())
+	ts.login(t, "Alice", "alice@example.com")
+
+	_, _, body := ts.get(t, "/snippet/create")
+	assert.StringContains(t, body, "value='30' checked")
+	assert.StringContains(t, body, "30 Days")
+	assert.Equal(t, strings.Contains(body, "One Year"), false)
+	csrfToken := extractCSRFToken(t, body)
+
+	post := func(expires string, expiresAt string) (int, http.Header, string) {
+		form := url.Values{}
+		form.Add("title", "Expiring")
+		form.Add("content", "some content")
+		form.Add("expires", expires)
+		form.Add("expires_at", expiresAt)
+		form.Add("csrf_token", csrfToken)
+		return ts.postForm(t, "/snippet/create", form)
+	}
+
+	code, _, body := post("7", "")
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, body, ErrExpiresInvalid)
+
+	for _, expiresAt := range []string{"", "tomorrow", "2001-01-01T00:00", time.Now().AddDate(20, 0, 0).Format("2006-01-02T15:04")} {
+		code, _, body = post("-2", expiresAt)
+		assert.Equal(t, code, http.StatusUnprocessableEntity)
+		assert.StringContains(t, body, ErrExpiresAtInvalid)
+	}
+
+	code, header, _ := post("-1", "")
+	assert.Equal(t, code, http.StatusSeeOther)
+	_, _, body = ts.get(t, header.Get("Location"))
+	assert.StringContains(t, body, "Expires: Never")
+
+	at := time.Now().UTC().AddDate(0, 0, 3).Truncate(time.Minute)
+	code, header, _ = post("-2", at.Format("2006-01-02T15:04"))
+	assert.Equal(t, code, http.StatusSeeOther)
+	_, _, body = ts.get(t, header.Get("Location"))
+	assert.StringContains(t, body, "Expires: "+humanDate(at))
+
+	snippet, err := app.snippets.Get(1)
+	assert.NilError(t, err)
+	assert.Equal(t, snippet.Expires == nil, true)
+
+	// Editing keeps the snippet never expiring unless another option is picked.
+	_, _, body = ts.get(t, "/snippet/edit/1")
+	assert.StringContains(t, body, "Keep (never)")
+	form := url.Values{}
+	form.Add("title", "Expiring")
+	form.Add("content", "edited content")
+	form.Add("expires", "0")
+	form.Add("csrf_token", extractCSRFToken(t, body))
+	code, _, _ = ts.postForm(t, "/snippet/edit/1", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	snippet, err = app.snippets.Get(1)
+	assert.NilError(t, err)
+	assert.Equal(t, snippet.Expires == nil, true)
+	assert.Equal(t, snippet.Content, "edited content")
+}
+
+func TestSnippetFiles(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	ts.login(t, "Alice", "alice@example.com")
+
+	_, _, body := ts.get(t, "/snippet/create")
+	assert.StringContains(t, body, "name='files[0].content'")
+	csrfToken := extractCSRFToken(t, body)
+
+	post := func(files ...[3]string) (int, http.Header, string) {
+		form := url.Values{}
+		form.Add("title", "Hello module")
+		form.Add("expires", "7")
+		form.Add("csrf_token", csrfToken)
+		for i, file := range files {
+			form.Add(fmt.Sprintf("files[%d].name", i), file[0])
+			form.Add(fmt.Sprintf("files[%d].language", i), file[1])
+			form.Add(fmt.Sprintf("files[%d].content", i), file[2])
+		}
+		return ts.postForm(t, "/snippet/create", form)
+	}
+
+	tests := []struct {
+		name    string
+		files   [][3]string
+		wantErr string
+	}{
+		{name: "Blank content", files: [][3]string{{"main.go", "go", ""}}, wantErr: ErrContentInvalid},
+		{name: "Slash", files: [][3]string{{"cmd/main.go", "go", "package main"}}, wantErr: ErrFileNameInvalid},
+		{name: "Duplicate", files: [][3]string{{"a.txt", "", "a"}, {"a.txt", "", "b"}}, wantErr: ErrFileNameDuplicate},
+		{name: "Language", files: [][3]string{{"a.txt", "klingon", "a"}}, wantErr: ErrLanguageInvalid},
+		{name: "Too many", files: slices.Repeat([][3]string{{"", "", "x"}}, 11), wantErr: ErrFilesTooMany},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := post(tt.files...)
+			assert.Equal(t, code, http.StatusUnprocessableEntity)
+			assert.StringContains(t, body, html.EscapeString(tt.wantErr))
+		})
+	}
+
+	// Empty rows are dropped.
+	code, _, _ := post([3]string{"main.go", "go", "package main"}, [3]string{}, [3]string{"go.mod", "", "module hello"})
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	snippet, err := app.snippets.Get(1)
+	assert.NilError(t, err)
+	assert.Equal(t, len(snippet.Files), 2)
+
+	_, _, body = ts.get(t, "/snippet/view/1")
+	assert.Equal(t, strings.Index(body, "main.go") < strings.Index(body, "go.mod"), true)
+	assert.StringContains(t, body, `id="L1"`)
+	assert.StringContains(t, body, `<a class="lnlinks" href="#F2-L1">1</a>`)
+	assert.StringContains(t, body, "/snippet/archive/1")
+
+	_, _, body = ts.get(t, "/snippet/edit/1")
+	assert.StringContains(t, body, "name='files[1].name' value='go.mod'")
+
+	code, header, body := ts.get(t, "/snippet/archive/1")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, header.Get("Content-Type"), "application/zip")
+	assert.Equal(t, header.Get("Content-Disposition"), "attachment; filename=Hello-module.zip")
+
+	zr, err := zip.NewReader(strings.NewReader(body), int64(len(body)))
+	assert.NilError(t, err)
+	assert.Equal(t, len(zr.File), 2)
+	assert.Equal(t, zr.File[0].Name, "main.go")
+	assert.Equal(t, zr.File[1].Name, "go.mod")
+	rc, err := zr.File[1].Open()
+	assert.NilError(t, err)
+	content, err := io.ReadAll(rc)
+	assert.NilError(t, err)
+	assert.Equal(t, string(content), "module hello")
+
+	code, _, _ = ts.get(t, "/snippet/archive/2")
+	assert.Equal(t, code, http.StatusNotFound)
+
+	// The raw and download routes serve the file picked by its number.
+	_, _, body = ts.get(t, "/snippet/view/1")
+	assert.StringContains(t, body, "<a href='/snippet/raw/1?file=2'>Raw</a>")
+
+	_, header, body = ts.get(t, "/snippet/raw/1")
+	assert.Equal(t, body, "package main")
+	etag := header.Get("ETag")
+
+	code, header, body = ts.get(t, "/snippet/raw/1?file=2")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, body, "module hello")
+	assert.Equal(t, header.Get("ETag") != etag, true)
+
+	code, header, _ = ts.get(t, "/snippet/download/1?file=2")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, header.Get("Content-Disposition"), "attachment; filename=go.mod")
+
+	code, _, _ = ts.get(t, "/snippet/raw/1?file=3")
+	assert.Equal(t, code, http.StatusNotFound)
+
+	code, _, _ = ts.get(t, "/snippet/raw/1?file=x")
+	assert.Equal(t, code, http.StatusBadRequest)
+}
+
+func TestSnippetFork(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	_, err := app.snippets.Insert(0, models.SnippetInput{Title: "Original", Content: "fmt.Println(1)", Language: "go", Expires: 7, Tags: []string{"go"}})
+	assert.NilError(t, err)
+	_, err = app.snippets.Insert(0, models.SnippetInput{Title: "Secret", Content: "ciphertext", Encrypted: true, Expires: 7})
+	assert.NilError(t, err)
+
+	code, header, _ := ts.get(t, "/snippet/fork/1")
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, header.Get("Location"), "/user/login")
+
+	ts.login(t, "Alice", "alice@example.com")
+
+	_, _, body := ts.get(t, "/snippet/view/1")
+	assert.StringContains(t, body, "/snippet/fork/1")
+
+	code, _, body = ts.get(t, "/snippet/fork/1")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "name='parent' value='1'")
+	assert.StringContains(t, body, "value='Original'")
+	assert.StringContains(t, body, "fmt.Println(1)")
+	csrfToken := extractCSRFToken(t, body)
+
+	code, header, _ = ts.get(t, "/snippet/fork/2")
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, header.Get("Location"), "/snippet/view/2")
+
+	post := func(parent string) (int, http.Header, string) {
+		form := url.Values{}
+		form.Add("title", "Fork")
+		form.Add("content", "fmt.Println(2)")
+		form.Add("expires", "7")
+		form.Add("parent", parent)
+		form.Add("csrf_token", csrfToken)
+		return ts.postForm(t, "/snippet/create", form)
+	}
+
+	for _, parent := range []string{"2", "99"} {
+		code, _, body = post(parent)
+		assert.Equal(t, code, http.StatusUnprocessableEntity)
+		assert.StringContains(t, body, html.EscapeString(ErrParentInvalid))
+	}
+
+	code, header, _ = post("1")
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, header.Get("Location"), "/snippet/view/3")
+
+	snippet, err := app.snippets.Get(3)
+	assert.NilError(t, err)
+	assert.Equal(t, snippet.ParentID, 1)
+
+	_, _, body = ts.get(t, "/snippet/view/3")
+	assert.StringContains(t, body, "Forked from <a href='/snippet/view/1'>#1 Original</a>")
+
+	_, _, body = ts.get(t, "/snippet/view/1")
+	assert.StringContains(t, body, "<a href='/snippet/view/3'>Fork</a> by Alice")
+
+	// Unlisted snippets can only be forked through their slug.
+	unlisted, err := app.snippets.Insert(0, models.SnippetInput{Title: "Unlisted", Content: "hidden", Expires: 7,
+		Visibility: models.VisibilityUnlisted})
+	assert.NilError(t, err)
+	snippet, err = app.snippets.Get(unlisted)
+	assert.NilError(t, err)
+
+	code, _, body = post(fmt.Sprint(unlisted))
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, body, html.EscapeString(ErrParentInvalid))
+
+	_, _, body = ts.get(t, "/snippet/fork/"+snippet.Slug)
+	assert.StringContains(t, body, "name='parent' value='"+snippet.Slug+"'")
+	assert.StringContains(t, body, "<a href='/snippet/view/"+snippet.Slug+"'>#4</a>")
+
+	code, header, _ = post(snippet.Slug)
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, header.Get("Location"), "/snippet/view/5")
+
+	snippet, err = app.snippets.Get(5)
+	assert.NilError(t, err)
+	assert.Equal(t, snippet.ParentID, unlisted)
+}
+
+func TestSnippetStars(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	_, err := app.snippets.Insert(0, models.SnippetInput{Title: "Useful", Content: "useful", Expires: 7})
+	assert.NilError(t, err)
+	_, err = app.snippets.Insert(0, models.SnippetInput{Title: "Burn", Content: "burn", Expires: 7, MaxViews: 1})
+	assert.NilError(t, err)
+
+	_, _, body := ts.get(t, "/snippet/view/1")
+	assert.StringContains(t, body, "0 stars")
+
+	_, _, body = ts.get(t, "/user/login")
+	form := url.Values{}
+	form.Add("csrf_token", extractCSRFToken(t, body))
+	code, header, _ := ts.postForm(t, "/snippet/star/1", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, header.Get("Location"), "/user/login")
+
+	ts.login(t, "Alice", "alice@example.com")
+	_, _, body = ts.get(t, "/snippet/view/1")
+	assert.StringContains(t, body, "<button>Star (0)</button>")
+	form.Set("csrf_token", extractCSRFToken(t, body))
+
+	code, _, _ = ts.postForm(t, "/snippet/star/1", url.Values{})
+	assert.Equal(t, code, http.StatusBadRequest)
+
+	code, header, _ = ts.postForm(t, "/snippet/star/1", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, header.Get("Location"), "/snippet/view/1")
+
+	_, _, body = ts.get(t, "/snippet/view/1")
+	assert.StringContains(t, body, "action='/snippet/unstar/1'")
+	assert.StringContains(t, body, "<button>Unstar (1)</button>")
+
+	code, _, _ = ts.postForm(t, "/snippet/star/2", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+	code, _, _ = ts.postForm(t, "/snippet/star/99", form)
+	assert.Equal(t, code, http.StatusNotFound)
+
+	_, _, body = ts.get(t, "/user/starred")
+	assert.StringContains(t, body, "Useful")
+	assert.Equal(t, strings.Contains(body, "Burn"), false)
+
+	_, _, body = ts.get(t, "/")
+	assert.StringContains(t, body, "Most Starred This Week")
+
+	code, _, _ = ts.postForm(t, "/snippet/unstar/1", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	_, _, body = ts.get(t, "/user/starred")
+	assert.StringContains(t, body, "You haven't starred any snippets yet.")
+
+	_, _, body = ts.get(t, "/")
+	assert.Equal(t, strings.Contains(body, "Most Starred This Week"), false)
+}
+
+func TestSnippetComments(t *testing.T) {
+	app := newTestApplication(t)
+	alice := newTestServer(t, app.routes())
+	alice.login(t, "Alice", "alice@example.com")
+	bob := newTestServer(t, app.routes())
+	bob.login(t, "Bob", "bob@example.com")
+	carol := newTestServer(t, app.routes())
+	carol.login(t, "Carol", "carol@example.com")
+
+	_, err := app.snippets.Insert(1, models.SnippetInput{Title: "Hello", Content: "one\ntwo\nthree\n", Expires: 7})
+	assert.NilError(t, err)
+
+	_, _, body := bob.get(t, "/snippet/view/1")
+	assert.StringContains(t, body, "No comments yet.")
+	assert.StringContains(t, body, "action='/snippet/comment/1'")
+	csrfToken := extractCSRFToken(t, body)
+
+	comment := func(ts *testServer, csrfToken, text, line string) (int, http.Header, string) {
+		form := url.Values{}
+		form.Add("body", text)
+		form.Add("line", line)
+		form.Add("csrf_token", csrfToken)
+		return ts.postForm(t, "/snippet/comment/1", form)
+	}
+
+	tests := []struct {
+		name    string
+		body    string
+		line    string
+		wantErr string
+	}{
+		{name: "Blank", body: " ", wantErr: ErrCommentBlank},
+		{name: "Too long", body: strings.Repeat("a", 2001), wantErr: ErrCommentTooLong},
+		{name: "Past the last line", body: "Hm", line: "4", wantErr: ErrCommentLineInvalid},
+		{name: "Negative line", body: "Hm", line: "-1", wantErr: ErrCommentLineInvalid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := comment(bob, csrfToken, tt.body, tt.line)
+			assert.Equal(t, code, http.StatusUnprocessableEntity)
+			assert.StringContains(t, body, html.EscapeString(tt.wantErr))
+		})
+	}
+
+	code, header, _ := comment(bob, csrfToken, "Should this be 2?", "2")
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, header.Get("Location"), "/snippet/view/1#comment-1")
+	code, _, _ = comment(bob, csrfToken, "Looks good", "")
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	_, _, body = alice.get(t, "/snippet/view/1")
+	assert.StringContains(t, body, "on <a href='#L2'>line 2</a>")
+	assert.StringContains(t, body, "Should this be 2?")
+	assert.StringContains(t, body, "action='/comment/delete/1'")
+	aliceToken := extractCSRFToken(t, body)
+
+	_, _, body = carol.get(t, "/snippet/view/1")
+	assert.Equal(t, strings.Contains(body, "action='/comment/delete/1'"), false)
+
+	form := url.Values{}
+	form.Add("csrf_token", extractCSRFToken(t, body))
+	code, _, _ = carol.postForm(t, "/comment/delete/1", form)
+	assert.Equal(t, code, http.StatusForbidden)
+
+	form.Set("csrf_token", aliceToken)
+	code, header, _ = alice.postForm(t, "/comment/delete/1", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, header.Get("Location"), "/snippet/view/1")
+	code, _, _ = alice.postForm(t, "/comment/delete/1", form)
+	assert.Equal(t, code, http.StatusNotFound)
+
+	form.Set("csrf_token", csrfToken)
+	code, _, _ = bob.postForm(t, "/comment/delete/2", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	comments, err := app.comments.ForSnippet(1)
+	assert.NilError(t, err)
+	assert.Equal(t, len(comments), 0)
+
+	// On a snippet with several files, the line is checked against and links
+	// to the chosen file.
+	files := []models.File{{Name: "main.go", Content: "package main\n\nfunc main() {}"}, {Name: "notes", Content: "todo"}}
+	_, err = app.snippets.Insert(1, models.SnippetInput{Title: "Module", Files: files, Expires: 7})
+	assert.NilError(t, err)
+
+	_, _, body = bob.get(t, "/snippet/view/2")
+	assert.StringContains(t, body, "<option value='2'>notes</option>")
+
+	form = url.Values{}
+	form.Add("body", "Done yet?")
+	form.Add("file", "2")
+	form.Add("line", "3")
+	form.Add("csrf_token", csrfToken)
+	code, _, body = bob.postForm(t, "/snippet/comment/2", form)
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, body, ErrCommentLineInvalid)
+	assert.StringContains(t, body, "<option value='2' selected>notes</option>")
+
+	form.Set("line", "1")
+	code, _, _ = bob.postForm(t, "/snippet/comment/2", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	_, _, body = alice.get(t, "/snippet/view/2")
+	assert.StringContains(t, body, "on <a href='#F2-L1'>line 1 of notes</a>")
+	assert.StringContains(t, body, "<input type='hidden' name='parent' value='3'>")
+
+	// Replies go under the comment they answer, and a reply to a reply joins
+	// its thread.
+	reply := func(ts *testServer, csrfToken, ref, text, parent string) (int, http.Header, string) {
+		form := url.Values{}
+		form.Add("body", text)
+		form.Add("parent", parent)
+		form.Add("csrf_token", csrfToken)
+		return ts.postForm(t, "/snippet/comment/"+ref, form)
+	}
+
+	code, header, _ = reply(alice, aliceToken, "2", "Almost", "3")
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, header.Get("Location"), "/snippet/view/2#comment-4")
+	code, _, _ = reply(bob, csrfToken, "2", "Great, thanks", "4")
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	comments, err = app.comments.ForSnippet(2)
+	assert.NilError(t, err)
+	assert.Equal(t, len(comments), 3)
+	assert.Equal(t, comments[1].ParentID, 3)
+	assert.Equal(t, comments[2].ParentID, 3)
+
+	_, _, body = alice.get(t, "/snippet/view/2")
+	positions := []int{strings.Index(body, "id='comment-3'"), strings.Index(body, "<div class='replies'>"),
+		strings.Index(body, "id='comment-4'"), strings.Index(body, "id='comment-5'"), strings.Index(body, "value='Reply'")}
+	assert.Equal(t, slices.IsSorted(positions) && positions[0] >= 0, true)
+
+	code, _, body = reply(bob, csrfToken, "2", "", "3")
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, body, ErrCommentBlank)
+
+	_, err = app.comments.Insert(1, 1, 0, 0, 0, "Elsewhere")
+	assert.NilError(t, err)
+	for _, parent := range []string{"6", "99"} {
+		code, _, body = reply(bob, csrfToken, "2", "Lost", parent)
+		assert.Equal(t, code, http.StatusUnprocessableEntity)
+		assert.StringContains(t, body, ErrReplyInvalid)
+	}
+
+	// Deleting a comment deletes its replies.
+	form = url.Values{}
+	form.Add("csrf_token", csrfToken)
+	code, _, _ = bob.postForm(t, "/comment/delete/3", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+	comments, err = app.comments.ForSnippet(2)
+	assert.NilError(t, err)
+	assert.Equal(t, len(comments), 0)
+}
+
+func TestSnippetFilenames(t *testing.T) {
+	snippet := models.Snippet{ID: 1, Title: "Hello", Language: "go", Content: "package main", Files: []models.File{
+		{Language: "go", Content: "package main"},
+		{Name: "go.mod", Content: "module hello"},
+		{Language: "python", Content: "print(1)"},
+		{Name: "go.mod?", Content: "module other"},
+	}}
+
+	names := snippetFilenames(snippet)
+	assert.Equal(t, strings.Join(names, " "), "Hello.go go.mod file-3.py 4-go.mod")
+}
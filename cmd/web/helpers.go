@@ -2,13 +2,26 @@ package main
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/go-playground/form/v4"
 	"github.com/justinas/nosurf"
+	"io"
+	"mime"
 	"net/http"
+	"regexp"
 	"runtime/debug"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
+	"vtorosyan.learning/internal/highlight"
+	"vtorosyan.learning/internal/models"
+	"vtorosyan.learning/internal/validator"
 )
 
 func (app *application) serverError(w http.ResponseWriter, r *http.Request, err error) {
@@ -46,10 +59,12 @@ func (app *application) render(w http.ResponseWriter, r *http.Request, status in
 
 func (app *application) newTemplateData(r *http.Request) templateData {
 	return templateData{
-		CurrentYear:     time.Now().Year(),
-		Flash:           app.sessionManager.PopString(r.Context(), "flash"),
-		IsAuthenticated: app.isAuthenticate(r),
-		CSRFToken:       nosurf.Token(r),
+		CurrentYear:         time.Now().Year(),
+		Flash:               app.sessionManager.PopString(r.Context(), "flash"),
+		IsAuthenticated:     app.isAuthenticate(r),
+		AuthenticatedUserID: app.authenticatedUserID(r),
+		CSRFToken:           nosurf.Token(r),
+		ExpiryPresets:       app.expiryPresets,
 	}
 }
 
@@ -72,6 +87,260 @@ func (app *application) decodePostForm(r *http.Request, dst any) error {
 	return nil
 }
 
+// authenticatedUserID returns the id of the user making the request, whether
+// they signed in with a session cookie or an API token, or 0 if anonymous.
+func (app *application) authenticatedUserID(r *http.Request) int {
+	id, ok := r.Context().Value(authenticatedUserIDContextKey).(int)
+	if !ok {
+		return 0
+	}
+	return id
+}
+
+// ownedSnippet fetches the snippet named by the {id} path value and checks
+// that the current user owns it. When it reports false a 404 or 403 response
+// has already been written.
+func (app *application) ownedSnippet(w http.ResponseWriter, r *http.Request) (models.Snippet, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id < 1 {
+		app.clientError(w, http.StatusNotFound)
+		return models.Snippet{}, false
+	}
+
+	snippet, err := app.snippets.Get(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.clientError(w, http.StatusNotFound)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return models.Snippet{}, false
+	}
+
+	userID := app.authenticatedUserID(r)
+	if !snippet.VisibleTo(userID, false) {
+		app.clientError(w, http.StatusNotFound)
+		return models.Snippet{}, false
+	}
+	if snippet.UserID == 0 || snippet.UserID != userID {
+		app.clientError(w, http.StatusForbidden)
+		return models.Snippet{}, false
+	}
+
+	return snippet, true
+}
+
+// viewableSnippet fetches the snippet named by the {id} path value, which is
+// either its id or, for unlisted snippets, its slug. It returns
+// models.ErrNoRecord when the current user may not view the snippet, so that
+// hidden snippets cannot be told apart from missing ones.
+func (app *application) viewableSnippet(r *http.Request) (models.Snippet, error) {
+	return app.snippetByRef(r, r.PathValue("id"))
+}
+
+// snippetByRef fetches the snippet a Snippet.Ref names, checking like
+// viewableSnippet that the current user may view it that way.
+func (app *application) snippetByRef(r *http.Request, ref string) (models.Snippet, error) {
+	id, err := strconv.Atoi(ref)
+	bySlug := err != nil
+
+	var snippet models.Snippet
+	switch {
+	case bySlug:
+		snippet, err = app.snippets.GetBySlug(ref)
+	case id < 1:
+		return models.Snippet{}, models.ErrNoRecord
+	default:
+		snippet, err = app.snippets.Get(id)
+	}
+	if err != nil {
+		return models.Snippet{}, err
+	}
+
+	if !snippet.VisibleTo(app.authenticatedUserID(r), bySlug) {
+		return models.Snippet{}, models.ErrNoRecord
+	}
+
+	return snippet, nil
+}
+
+const (
+	// unlockLifetime is how long a protected snippet stays unlocked in the
+	// session after its password has been entered.
+	unlockLifetime = 30 * time.Minute
+	// unlockAttempts passwords can be tried per snippet every unlockWindow.
+	unlockAttempts = 5
+	unlockWindow   = time.Minute
+)
+
+// unlockKey is the session key recording until when a snippet is unlocked.
+func unlockKey(snippetID int) string {
+	return fmt.Sprintf("unlockedSnippet:%d", snippetID)
+}
+
+// unlocked reports whether the current user may see the content of the
+// snippet. Snippets without a password always are unlocked; protected ones
+// are for their owner, and for anyone who entered the password in the last
+// unlockLifetime.
+func (app *application) unlocked(r *http.Request, snippet models.Snippet) bool {
+	if !snippet.Protected {
+		return true
+	}
+	if snippet.UserID != 0 && snippet.UserID == app.authenticatedUserID(r) {
+		return true
+	}
+
+	until := app.sessionManager.GetInt64(r.Context(), unlockKey(snippet.ID))
+	return time.Now().Unix() < until
+}
+
+// viewLimited reports whether showing the snippet to the current user uses
+// up one of its views. Owners can look at their snippets freely.
+func (app *application) viewLimited(r *http.Request, snippet models.Snippet) bool {
+	if snippet.MaxViews == 0 {
+		return false
+	}
+	return snippet.UserID == 0 || snippet.UserID != app.authenticatedUserID(r)
+}
+
+// editable checks that the snippet can be edited, which encrypted snippets
+// cannot as the server has no way to decrypt them. When it reports false the
+// user has already been sent back to the snippet.
+func (app *application) editable(w http.ResponseWriter, r *http.Request, snippet models.Snippet) bool {
+	if !snippet.Encrypted {
+		return true
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Encrypted snippets can not be edited.")
+	http.Redirect(w, r, "/snippet/view/"+snippet.Ref(), http.StatusSeeOther)
+	return false
+}
+
+// forkable reports whether the current user may fork a snippet they can view:
+// it must be unlocked and without a view limit for them, and not encrypted,
+// as the server could not copy its plaintext.
+func (app *application) forkable(r *http.Request, snippet models.Snippet) bool {
+	return app.unlocked(r, snippet) && !app.viewLimited(r, snippet) && !snippet.Encrypted
+}
+
+// validateReply checks that the comment a form replies to, if any, exists and
+// is on the same snippet, recording a field error otherwise. Threads are one
+// level deep, so a reply to a reply joins the thread of the comment it is in.
+func (app *application) validateReply(form *commentCreateForm, snippet models.Snippet) error {
+	if form.Parent == 0 {
+		return nil
+	}
+
+	parent, err := app.comments.Get(form.Parent)
+	if err != nil && !errors.Is(err, models.ErrNoRecord) {
+		return err
+	}
+
+	if err != nil || parent.SnippetID != snippet.ID {
+		form.AddFieldError("parent", ErrReplyInvalid)
+		form.Parent = 0
+		return nil
+	}
+
+	if parent.ParentID != 0 {
+		form.Parent = parent.ParentID
+	}
+	return nil
+}
+
+// validateParent checks that the snippet a form forks, if any, still exists
+// and can be forked by the current user, recording a field error otherwise.
+// The HTML form names the parent by its Ref, so unlisted snippets can only be
+// forked by those who have their slug; API clients give its id. A valid
+// parent is resolved into both fields.
+func (app *application) validateParent(r *http.Request, form *snippetCreateForm) error {
+	ref := form.Parent
+	if ref == "" && form.ParentID != 0 {
+		ref = strconv.Itoa(form.ParentID)
+	}
+	if ref == "" {
+		return nil
+	}
+
+	parent, err := app.snippetByRef(r, ref)
+	if err != nil && !errors.Is(err, models.ErrNoRecord) {
+		return err
+	}
+
+	if err != nil || !app.forkable(r, parent) {
+		form.AddFieldError("parent", ErrParentInvalid)
+		form.ParentID = 0
+		return nil
+	}
+
+	form.Parent, form.ParentID = parent.Ref(), parent.ID
+	return nil
+}
+
+// snippetViewData is the template data of the view page: the snippet, the
+// snippet it was forked from when the current user may see it, its public
+// forks, its comments with an empty comment form, and whether the current
+// user starred it.
+func (app *application) snippetViewData(r *http.Request, snippet models.Snippet) (templateData, error) {
+	data := app.newTemplateData(r)
+	data.Snippet = snippet
+
+	if snippet.ParentID != 0 {
+		parent, err := app.snippets.Get(snippet.ParentID)
+		if err != nil && !errors.Is(err, models.ErrNoRecord) {
+			return templateData{}, err
+		}
+		if err == nil && parent.VisibleTo(app.authenticatedUserID(r), false) {
+			data.Parent = &parent
+		}
+	}
+
+	forks, err := app.snippets.Forks(snippet.ID)
+	if err != nil {
+		return templateData{}, err
+	}
+	data.Forks = forks
+
+	comments, err := app.comments.ForSnippet(snippet.ID)
+	if err != nil {
+		return templateData{}, err
+	}
+	data.Comments = commentThreads(comments)
+	data.Form = commentCreateForm{}
+
+	if userID := app.authenticatedUserID(r); userID != 0 {
+		data.Starred, err = app.snippets.HasStarred(snippet.ID, userID)
+		if err != nil {
+			return templateData{}, err
+		}
+	}
+
+	return data, nil
+}
+
+// ciphertextPrefix starts the content of encrypted snippets, as written by
+// main.js. It names the format: the base64 of a 12 byte AES-GCM nonce followed
+// by the ciphertext and its 16 byte tag.
+const ciphertextPrefix = "v1."
+
+// validCiphertext reports whether content looks like what main.js produces
+// when it encrypts a snippet. The server cannot check any more than that.
+func validCiphertext(content string) bool {
+	encoded, ok := strings.CutPrefix(content, ciphertextPrefix)
+	if !ok {
+		return false
+	}
+
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	return err == nil && len(data) >= 12+16
+}
+
+// bearerToken returns the API token from an "Authorization: Bearer" header.
+func bearerToken(r *http.Request) (string, bool) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return strings.TrimSpace(token), ok
+}
+
 func (app *application) isAuthenticate(r *http.Request) bool {
 	isAuthenticated, ok := r.Context().Value(isAuthenticatedContextKey).(bool)
 	if !ok {
@@ -79,3 +348,212 @@ func (app *application) isAuthenticate(r *http.Request) bool {
 	}
 	return isAuthenticated
 }
+
+// envelope wraps every JSON API response in a named top-level object.
+type envelope map[string]any
+
+func (app *application) writeJSON(w http.ResponseWriter, status int, data envelope, headers http.Header) error {
+	js, err := json.MarshalIndent(data, "", "\t")
+	if err != nil {
+		return err
+	}
+	js = append(js, '\n')
+
+	for key, value := range headers {
+		w.Header()[key] = value
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, err = w.Write(js)
+	return err
+}
+
+// readJSON decodes a single JSON object from the request body into dst,
+// rejecting unknown fields and bodies larger than 1MB.
+func (app *application) readJSON(w http.ResponseWriter, r *http.Request, dst any) error {
+	r.Body = http.MaxBytesReader(w, r.Body, 1_048_576)
+
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+
+	err := dec.Decode(dst)
+	if err != nil {
+		var syntaxError *json.SyntaxError
+		var unmarshalTypeError *json.UnmarshalTypeError
+		var maxBytesError *http.MaxBytesError
+
+		switch {
+		case errors.As(err, &syntaxError):
+			return fmt.Errorf("body contains badly-formed JSON (at character %d)", syntaxError.Offset)
+		case errors.Is(err, io.ErrUnexpectedEOF):
+			return errors.New("body contains badly-formed JSON")
+		case errors.As(err, &unmarshalTypeError):
+			if unmarshalTypeError.Field != "" {
+				return fmt.Errorf("body contains incorrect JSON type for field %q", unmarshalTypeError.Field)
+			}
+			return fmt.Errorf("body contains incorrect JSON type (at character %d)", unmarshalTypeError.Offset)
+		case errors.Is(err, io.EOF):
+			return errors.New("body must not be empty")
+		case errors.As(err, &maxBytesError):
+			return fmt.Errorf("body must not be larger than %d bytes", maxBytesError.Limit)
+		default:
+			return err
+		}
+	}
+
+	err = dec.Decode(&struct{}{})
+	if !errors.Is(err, io.EOF) {
+		return errors.New("body must only contain a single JSON value")
+	}
+
+	return nil
+}
+
+func (app *application) errorJSON(w http.ResponseWriter, r *http.Request, status int, message any) {
+	err := app.writeJSON(w, status, envelope{"error": message}, nil)
+	if err != nil {
+		app.logger.Error(err.Error(), "URI", r.RequestURI, "Method", r.Method)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+func (app *application) serverErrorJSON(w http.ResponseWriter, r *http.Request, err error) {
+	trace := string(debug.Stack())
+	app.logger.Error(err.Error(), "URI", r.RequestURI, "Method", r.Method, "Trace", trace)
+	app.errorJSON(w, r, http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+}
+
+// failedValidationJSON reports the field errors collected by a
+// validator.Validator, keyed by field name.
+func (app *application) failedValidationJSON(w http.ResponseWriter, r *http.Request, v validator.Validator) {
+	err := app.writeJSON(w, http.StatusUnprocessableEntity, envelope{
+		"error":        "validation failed",
+		"field_errors": v.FieldErrors,
+	}, nil)
+	if err != nil {
+		app.logger.Error(err.Error(), "URI", r.RequestURI, "Method", r.Method)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// unsafeFilenameChars matches everything that is not kept when a snippet
+// title is turned into a file name, including runs of dots.
+var unsafeFilenameChars = regexp.MustCompile(`([^A-Za-z0-9._-]|\.\.)+`)
+
+// safeFilename keeps only the ASCII letters, digits, dots, underscores and
+// hyphens of name, so that it is safe in a Content-Disposition header and on
+// any file system, and shortens it to 64 bytes.
+func safeFilename(name string) string {
+	name = strings.Trim(unsafeFilenameChars.ReplaceAllString(name, "-"), "-.")
+	if len(name) > 64 {
+		name = strings.TrimRight(name[:64], "-.")
+	}
+	return name
+}
+
+// snippetBasename derives a file name without extension from the snippet's
+// title.
+func snippetBasename(snippet models.Snippet) string {
+	name := safeFilename(snippet.Title)
+	if name == "" {
+		name = fmt.Sprintf("snippet-%d", snippet.ID)
+	}
+	return name
+}
+
+// snippetFilename derives a download file name from the snippet's title and
+// the language of its first file.
+func snippetFilename(snippet models.Snippet) string {
+	return snippetBasename(snippet) + highlight.Ext(snippetLanguageID(snippet))
+}
+
+// snippetFilenames derives a distinct file name for each file of the snippet:
+// its own name made safe, or for unnamed files snippetFilename for the first
+// one and a numbered name for the rest. Names made equal by dropping unsafe
+// characters are told apart by the file's number.
+func snippetFilenames(snippet models.Snippet) []string {
+	names := make([]string, len(snippet.Files))
+	for i, file := range snippet.Files {
+		name := safeFilename(file.Name)
+		switch {
+		case name != "":
+		case i == 0:
+			name = snippetFilename(snippet)
+		default:
+			name = fmt.Sprintf("file-%d%s", i+1, highlight.Ext(fileLanguageID(snippet, file)))
+		}
+		if slices.Contains(names[:i], name) {
+			name = fmt.Sprintf("%d-%s", i+1, name)
+		}
+		names[i] = name
+	}
+	return names
+}
+
+// contentETag is a strong validator for the content of a file of a snippet.
+func contentETag(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// readableSnippet returns the snippet in the id path value for serving its
+// bare content. When the current user may not view it, or it is locked or has
+// a view limit, it answers the request itself and returns false.
+func (app *application) readableSnippet(w http.ResponseWriter, r *http.Request) (models.Snippet, bool) {
+	snippet, err := app.viewableSnippet(r)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.clientError(w, http.StatusNotFound)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return models.Snippet{}, false
+	}
+
+	if !app.unlocked(r, snippet) || app.viewLimited(r, snippet) {
+		app.clientError(w, http.StatusForbidden)
+		return models.Snippet{}, false
+	}
+
+	return snippet, true
+}
+
+// serveSnippetContent writes the bare content of a file of the snippet in the
+// id path value, if the current user may view it, it is unlocked and it has no
+// view limit, as plain text, as an attachment if asked to. The file query
+// parameter picks the file by its number, the first one by default.
+// http.ServeContent answers conditional requests from the ETag and
+// Last-Modified headers, and range requests too, which lets downloads resume.
+func (app *application) serveSnippetContent(w http.ResponseWriter, r *http.Request, attachment bool) {
+	snippet, ok := app.readableSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	i := 0
+	if r.URL.Query().Has("file") {
+		n, err := strconv.Atoi(r.URL.Query().Get("file"))
+		if err != nil {
+			app.clientError(w, http.StatusBadRequest)
+			return
+		}
+		if n < 1 || n > len(snippet.Files) {
+			app.clientError(w, http.StatusNotFound)
+			return
+		}
+		i = n - 1
+	}
+	content := snippet.Files[i].Content
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("ETag", contentETag(content))
+	if attachment {
+		w.Header().Set("Content-Disposition",
+			mime.FormatMediaType("attachment", map[string]string{"filename": snippetFilenames(snippet)[i]}))
+	}
+
+	http.ServeContent(w, r, "", snippet.Updated, strings.NewReader(content))
+}
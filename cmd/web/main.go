@@ -1,34 +1,66 @@
 package main
 
 import (
+	"context"
 	"crypto/tls"
 	"database/sql"
+	"errors"
 	"flag"
 	"github.com/alexedwards/scs/mysqlstore"
+	"github.com/alexedwards/scs/postgresstore"
+	"github.com/alexedwards/scs/sqlite3store"
 	"github.com/alexedwards/scs/v2"
+	"github.com/alexedwards/scs/v2/memstore"
 	"github.com/go-playground/form/v4"
 	"html/template"
 	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
 	"time"
 	"vtorosyan.learning/internal/models"
 
 	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	_ "modernc.org/sqlite"
 )
 
+const (
+	// memoryDSN selects the in-memory stores instead of a database.
+	memoryDSN = "memory:"
+	// sqliteScheme prefixes the path of a SQLite database file.
+	sqliteScheme = "sqlite:"
+)
+
+// postgresSchemes are the URL schemes of PostgreSQL connection strings.
+var postgresSchemes = []string{"postgres://", "postgresql://"}
+
 type application struct {
 	logger         *slog.Logger
-	snippets       *models.SnippetModel
-	users          *models.UserModel
+	snippets       models.SnippetStore
+	users          models.UserStore
+	tokens         models.TokenStore
+	comments       models.CommentStore
 	templateCache  map[string]*template.Template
 	formDecoder    *form.Decoder
 	sessionManager *scs.SessionManager
+	unlockLimiter  *attemptLimiter
+	expiryPresets  []int
 }
 
 func main() {
 	addr := flag.String("addr", ":4000", "HTTP port that the server needs to run")
-	dsn := flag.String("dsn", "user:password@/snippetbox?parseTime=true", "Database connection string")
+	dsn := flag.String("dsn", "user:password@/snippetbox?parseTime=true",
+		"Database connection string: a MySQL DSN, a postgres:// URL, \"sqlite:<path>\", or \"memory:\" to keep everything in memory")
+	reaperInterval := flag.Duration("reaper-interval", time.Hour, "How often expired snippets are purged, or 0 to never purge them")
+	reaperGrace := flag.Duration("reaper-grace", 0,
+		"How long expired snippets are kept, and still listed to their owners, before they are purged")
+	reaperBatch := flag.Int("reaper-batch", 500, "The most expired snippets deleted by a single statement")
+	expiryPresets := flag.String("expiry-presets", formatExpiryPresets(defaultExpiryPresets),
+		"Comma separated numbers of days snippets can be set to expire in, the first being the default")
 	flag.Parse()
 
 	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
@@ -36,23 +68,79 @@ func main() {
 		AddSource: true,
 	}))
 
-	db, err := openDB(*dsn)
+	if flag.Arg(0) == "migrate" {
+		err := runMigrate(logger, os.Stdout, *dsn, flag.Args()[1:])
+		if err != nil {
+			logger.Error(err.Error())
+			os.Exit(1)
+		}
+		return
+	}
+
+	presets, err := parseExpiryPresets(*expiryPresets)
 	if err != nil {
 		logger.Error(err.Error())
 		os.Exit(1)
 	}
 
-	defer func() {
-		err = db.Close()
+	sessionManager := scs.New()
+	sessionManager.Cookie.SameSite = http.SameSiteLaxMode
+	sessionManager.Lifetime = 12 * time.Hour
+
+	var (
+		snippets models.SnippetStore
+		users    models.UserStore
+		tokens   models.TokenStore
+		comments models.CommentStore
+	)
+
+	if *dsn == memoryDSN {
+		logger.Warn("Using in-memory storage, all data will be lost on exit.")
+		sessionManager.Store = memstore.New()
+		memoryUsers := models.NewMemoryUserModel()
+		memorySnippets := models.NewMemorySnippetModel(memoryUsers)
+		snippets = memorySnippets
+		users = memoryUsers
+		tokens = models.NewMemoryTokenModel()
+		comments = models.NewMemoryCommentModel(memorySnippets, memoryUsers)
+	} else {
+		db, dialect, err := openDB(*dsn)
 		if err != nil {
 			logger.Error(err.Error())
+			os.Exit(1)
 		}
-	}()
 
-	sessionManager := scs.New()
-	sessionManager.Cookie.SameSite = http.SameSiteLaxMode
-	sessionManager.Store = mysqlstore.New(db)
-	sessionManager.Lifetime = 12 * time.Hour
+		defer func() {
+			err = db.Close()
+			if err != nil {
+				logger.Error(err.Error())
+			}
+		}()
+
+		// A SQLite file is usually a local demo database, so keep it migrated
+		// without requiring a separate "migrate up" run.
+		if dialect == models.SQLite {
+			migrator := models.Migrator{DB: db, Dialect: dialect}
+			_, err = migrator.Up()
+			if err != nil {
+				logger.Error(err.Error())
+				os.Exit(1)
+			}
+		}
+
+		switch dialect {
+		case models.SQLite:
+			sessionManager.Store = sqlite3store.New(db)
+		case models.Postgres:
+			sessionManager.Store = postgresstore.New(db)
+		default:
+			sessionManager.Store = mysqlstore.New(db)
+		}
+		snippets = &models.SnippetModel{DB: db, Dialect: dialect}
+		users = &models.UserModel{DB: db, Dialect: dialect}
+		tokens = &models.TokenModel{DB: db, Dialect: dialect}
+		comments = &models.CommentModel{DB: db, Dialect: dialect}
+	}
 
 	templateCache, err := newTemplateCache()
 	if err != nil {
@@ -61,15 +149,17 @@ func main() {
 	}
 
 	formDecoder := form.NewDecoder()
-	snippets := models.SnippetModel{DB: db}
-	users := models.UserModel{DB: db}
 	app := &application{
 		logger:         logger,
-		snippets:       &snippets,
-		users:          &users,
+		snippets:       snippets,
+		users:          users,
+		tokens:         tokens,
+		comments:       comments,
 		templateCache:  templateCache,
 		formDecoder:    formDecoder,
 		sessionManager: sessionManager,
+		unlockLimiter:  newAttemptLimiter(unlockAttempts, unlockWindow),
+		expiryPresets:  presets,
 	}
 
 	tlsCfg := &tls.Config{
@@ -88,21 +178,66 @@ func main() {
 		WriteTimeout: 10 * time.Second,
 	}
 
+	// Interrupting or terminating the process stops the background work and
+	// lets requests in flight finish before main returns and closes the
+	// database.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var background sync.WaitGroup
+	if *reaperInterval > 0 {
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			app.runReaper(ctx, reaperConfig{interval: *reaperInterval, grace: *reaperGrace, batch: max(*reaperBatch, 1)})
+		}()
+	}
+
+	shutdownErr := make(chan error, 1)
+	go func() {
+		<-ctx.Done()
+		logger.Info("Shutting down the server.")
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		shutdownErr <- server.Shutdown(shutdownCtx)
+	}()
+
 	logger.Info("Starting the server.", "address", server.Addr)
 	err = server.ListenAndServeTLS("./tls/cert.pem", "./tls/key.pem")
+	if !errors.Is(err, http.ErrServerClosed) {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+
+	err = <-shutdownErr
+	if err != nil {
+		logger.Error(err.Error())
+	}
+	background.Wait()
 
-	logger.Error(err.Error())
-	os.Exit(1)
+	logger.Info("Stopped the server.")
 }
 
-func openDB(dsn string) (*sql.DB, error) {
-	db, err := sql.Open("mysql", dsn)
+func openDB(dsn string) (*sql.DB, models.Dialect, error) {
+	dialect := models.MySQL
+	if path, ok := strings.CutPrefix(dsn, sqliteScheme); ok {
+		dialect = models.SQLite
+		dsn = models.SQLiteDSN(path)
+	}
+	for _, scheme := range postgresSchemes {
+		if strings.HasPrefix(dsn, scheme) {
+			dialect = models.Postgres
+		}
+	}
+
+	db, err := sql.Open(dialect.DriverName(), dsn)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	err = db.Ping()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	return db, nil
+	return db, dialect, nil
 }
@@ -2,9 +2,11 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/justinas/nosurf"
 	"net/http"
+	"vtorosyan.learning/internal/models"
 )
 
 func commonHeaders(next http.Handler) http.Handler {
@@ -61,6 +63,20 @@ func (app *application) requireAuthentication(next http.Handler) http.Handler {
 	})
 }
 
+// requireAPIAuthentication is the JSON API counterpart of
+// requireAuthentication: clients get a 401 instead of a redirect to the login
+// page.
+func (app *application) requireAPIAuthentication(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !app.isAuthenticate(r) {
+			app.errorJSON(w, r, http.StatusUnauthorized, "you must be authenticated to access this resource")
+			return
+		}
+		w.Header().Add("Cache-Control", "no-store")
+		next.ServeHTTP(w, r)
+	})
+}
+
 func noSurf(next http.Handler) http.Handler {
 	csrfHandler := nosurf.New(next)
 	csrfHandler.SetBaseCookie(http.Cookie{
@@ -69,12 +85,36 @@ func noSurf(next http.Handler) http.Handler {
 		Secure:   true,
 	})
 
+	// Browsers never attach an Authorization header on their own, so requests
+	// carrying a bearer token cannot be forged cross-site. authenticate makes
+	// sure such requests are never authenticated by the session cookie.
+	csrfHandler.ExemptFunc(func(r *http.Request) bool {
+		_, ok := bearerToken(r)
+		return ok
+	})
+
 	return csrfHandler
 }
 
 func (app *application) authenticate(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		id := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+		var id int
+
+		if token, ok := bearerToken(r); ok {
+			userID, err := app.tokens.Authenticate(token)
+			if err != nil {
+				if errors.Is(err, models.ErrInvalidToken) {
+					w.Header().Set("WWW-Authenticate", "Bearer")
+					app.errorJSON(w, r, http.StatusUnauthorized, "invalid or revoked API token")
+				} else {
+					app.serverError(w, r, err)
+				}
+				return
+			}
+			id = userID
+		} else {
+			id = app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+		}
 
 		if id == 0 {
 			next.ServeHTTP(w, r)
@@ -89,6 +129,7 @@ func (app *application) authenticate(next http.Handler) http.Handler {
 
 		if exists {
 			ctx := context.WithValue(r.Context(), isAuthenticatedContextKey, true)
+			ctx = context.WithValue(ctx, authenticatedUserIDContextKey, id)
 			r = r.WithContext(ctx)
 		}
 		next.ServeHTTP(w, r)
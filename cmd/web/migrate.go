@@ -0,0 +1,70 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"text/tabwriter"
+	"vtorosyan.learning/internal/models"
+)
+
+const migrateUsage = "usage: web [flags] migrate up|down|status"
+
+// runMigrate implements the "migrate" command, which applies, rolls back or
+// lists the embedded schema migrations for the database selected by -dsn.
+func runMigrate(logger *slog.Logger, out io.Writer, dsn string, args []string) error {
+	if len(args) != 1 || !slices.Contains([]string{"up", "down", "status"}, args[0]) {
+		return errors.New(migrateUsage)
+	}
+	if dsn == memoryDSN {
+		return errors.New("the in-memory store has no schema to migrate")
+	}
+
+	db, dialect, err := openDB(dsn)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	migrator := models.Migrator{DB: db, Dialect: dialect}
+
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up()
+		for _, migration := range applied {
+			logger.Info("Applied migration.", "version", migration.Version, "name", migration.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			logger.Info("Schema is up to date.")
+		}
+	case "down":
+		migration, err := migrator.Down()
+		if err != nil {
+			return err
+		}
+		logger.Info("Rolled back migration.", "version", migration.Version, "name", migration.Name)
+	case "status":
+		statuses, err := migrator.Status()
+		if err != nil {
+			return err
+		}
+
+		tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "VERSION\tNAME\tAPPLIED")
+		for _, status := range statuses {
+			applied := "pending"
+			if status.Applied != nil {
+				applied = humanDate(*status.Applied)
+			}
+			fmt.Fprintf(tw, "%04d\t%s\t%s\n", status.Version, status.Name, applied)
+		}
+		return tw.Flush()
+	}
+
+	return nil
+}
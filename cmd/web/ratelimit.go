@@ -0,0 +1,60 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// attemptLimiter allows up to max attempts per key in each fixed window of
+// time. It is used to slow down guessing of snippet passwords, keyed by
+// snippet, so it holds no more than one small entry per snippet being tried.
+type attemptLimiter struct {
+	mu        sync.Mutex
+	max       int
+	window    time.Duration
+	windows   map[int]attemptWindow
+	lastSweep time.Time
+}
+
+type attemptWindow struct {
+	start time.Time
+	count int
+}
+
+func newAttemptLimiter(max int, window time.Duration) *attemptLimiter {
+	return &attemptLimiter{
+		max:     max,
+		window:  window,
+		windows: make(map[int]attemptWindow),
+	}
+}
+
+// allow records an attempt for key and reports whether it is within the
+// limit. When it is not, it also returns how long until the next attempt is
+// allowed.
+func (l *attemptLimiter) allow(key int) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(l.lastSweep) > l.window {
+		for k, w := range l.windows {
+			if now.Sub(w.start) >= l.window {
+				delete(l.windows, k)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	w, ok := l.windows[key]
+	if !ok || now.Sub(w.start) >= l.window {
+		w = attemptWindow{start: now}
+	}
+	if w.count >= l.max {
+		return false, w.start.Add(l.window).Sub(now)
+	}
+
+	w.count++
+	l.windows[key] = w
+	return true, 0
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// reaperConfig controls the background purge of expired snippets.
+type reaperConfig struct {
+	// interval is the time between purges.
+	interval time.Duration
+	// grace is how long expired snippets are kept before they are purged.
+	// Until then their owners still find them, marked as expired, in their
+	// own listing, which makes it a trash retention window.
+	grace time.Duration
+	// batch is the most snippets deleted by a single statement, so that a
+	// large purge never holds locks for long.
+	batch int
+}
+
+// runReaper purges expired snippets straight away and then every interval,
+// until ctx is cancelled.
+func (app *application) runReaper(ctx context.Context, cfg reaperConfig) {
+	app.logger.Info("Starting the expiry reaper.", "interval", cfg.interval, "grace", cfg.grace, "batch", cfg.batch)
+
+	ticker := time.NewTicker(cfg.interval)
+	defer ticker.Stop()
+
+	for {
+		app.reap(ctx, cfg)
+
+		select {
+		case <-ctx.Done():
+			app.logger.Info("Stopped the expiry reaper.")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// reap deletes, one batch at a time, every snippet which expired longer than
+// the grace period ago. It stops between batches once ctx is cancelled.
+func (app *application) reap(ctx context.Context, cfg reaperConfig) {
+	before := time.Now().Add(-cfg.grace)
+
+	total := 0
+	for ctx.Err() == nil {
+		n, err := app.snippets.PurgeExpired(before, cfg.batch)
+		if err != nil {
+			app.logger.Error(err.Error(), "purged", total)
+			return
+		}
+
+		total += n
+		if n < cfg.batch {
+			break
+		}
+	}
+
+	if total > 0 {
+		app.logger.Info("Purged expired snippets.", "count", total, "expired_before", before.UTC())
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+	"vtorosyan.learning/internal/assert"
+	"vtorosyan.learning/internal/models"
+)
+
+func TestReap(t *testing.T) {
+	app := newTestApplication(t)
+
+	_, err := app.snippets.Insert(1, models.SnippetInput{Title: "Live", Content: "live", Expires: 7})
+	assert.NilError(t, err)
+	for range 5 {
+		_, err = app.snippets.Insert(1, models.SnippetInput{Title: "Expired", Content: "expired", Expires: 0})
+		assert.NilError(t, err)
+	}
+
+	// Within the grace period expired snippets are kept.
+	app.reap(context.Background(), reaperConfig{grace: time.Hour, batch: 2})
+	owned, err := app.snippets.ForUser(1)
+	assert.NilError(t, err)
+	assert.Equal(t, len(owned), 6)
+
+	app.reap(context.Background(), reaperConfig{batch: 2})
+	owned, err = app.snippets.ForUser(1)
+	assert.NilError(t, err)
+	assert.Equal(t, len(owned), 1)
+	assert.Equal(t, owned[0].Title, "Live")
+}
+
+func TestRunReaperStops(t *testing.T) {
+	app := newTestApplication(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		app.runReaper(ctx, reaperConfig{interval: time.Hour, batch: 10})
+		close(done)
+	}()
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the reaper did not stop")
+	}
+}
@@ -11,12 +11,22 @@ func (app *application) routes() http.Handler {
 
 	// Static files
 	mux.Handle("GET /static/", http.FileServerFS(ui.Files))
+	mux.HandleFunc("GET /static/css/highlight.css", app.highlightCSS)
 
 	dynamic := alice.New(app.sessionManager.LoadAndSave, noSurf, app.authenticate)
 
 	// Unprotected handlers
 	mux.Handle("GET /{$}", dynamic.ThenFunc(app.home))
+	mux.Handle("GET /snippets", dynamic.ThenFunc(app.snippetList))
+	mux.Handle("GET /tag/{name}", dynamic.ThenFunc(app.tagView))
+	mux.Handle("GET /search", dynamic.ThenFunc(app.search))
 	mux.Handle("GET /snippet/view/{id}", dynamic.ThenFunc(app.snippetView))
+	mux.Handle("POST /snippet/view/{id}", dynamic.ThenFunc(app.snippetViewPost))
+	mux.Handle("POST /snippet/unlock/{id}", dynamic.ThenFunc(app.snippetUnlockPost))
+	mux.Handle("GET /snippet/raw/{id}", dynamic.ThenFunc(app.snippetRaw))
+	mux.Handle("GET /snippet/download/{id}", dynamic.ThenFunc(app.snippetDownload))
+	mux.Handle("GET /snippet/archive/{id}", dynamic.ThenFunc(app.snippetArchive))
+	mux.Handle("GET /snippet/view/{id}/history", dynamic.ThenFunc(app.snippetHistory))
 	mux.Handle("GET /user/signup", dynamic.ThenFunc(app.userSignup))
 	mux.Handle("POST /user/signup", dynamic.ThenFunc(app.userSignupPost))
 	mux.Handle("GET /user/login", dynamic.ThenFunc(app.userLogin))
@@ -26,7 +36,25 @@ func (app *application) routes() http.Handler {
 	// Protected handlers
 	mux.Handle("GET /snippet/create", protected.ThenFunc(app.snippetCreate))
 	mux.Handle("POST /snippet/create", protected.ThenFunc(app.snippetCreatePost))
+	mux.Handle("GET /snippet/fork/{id}", protected.ThenFunc(app.snippetFork))
+	mux.Handle("GET /snippet/edit/{id}", protected.ThenFunc(app.snippetEdit))
+	mux.Handle("POST /snippet/edit/{id}", protected.ThenFunc(app.snippetEditPost))
+	mux.Handle("POST /snippet/delete/{id}", protected.ThenFunc(app.snippetDeletePost))
+	mux.Handle("POST /snippet/star/{id}", protected.ThenFunc(app.snippetStarPost))
+	mux.Handle("POST /snippet/unstar/{id}", protected.ThenFunc(app.snippetUnstarPost))
+	mux.Handle("POST /snippet/comment/{id}", protected.ThenFunc(app.snippetCommentPost))
+	mux.Handle("POST /comment/delete/{id}", protected.ThenFunc(app.commentDeletePost))
 	mux.Handle("POST /user/logout", protected.ThenFunc(app.userLogoutPost))
+	mux.Handle("GET /user/snippets", protected.ThenFunc(app.userSnippets))
+	mux.Handle("GET /user/starred", protected.ThenFunc(app.userStarred))
+	mux.Handle("GET /user/tokens", protected.ThenFunc(app.userTokens))
+	mux.Handle("POST /user/tokens/create", protected.ThenFunc(app.userTokenCreatePost))
+	mux.Handle("POST /user/tokens/revoke/{id}", protected.ThenFunc(app.userTokenRevokePost))
+
+	// JSON API
+	mux.Handle("GET /api/v1/snippets", dynamic.ThenFunc(app.apiSnippetList))
+	mux.Handle("GET /api/v1/snippets/{id}", dynamic.ThenFunc(app.apiSnippetView))
+	mux.Handle("POST /api/v1/snippets", dynamic.Append(app.requireAPIAuthentication).ThenFunc(app.apiSnippetCreate))
 
 	standard := alice.New(app.recoverPanic, app.logRequests, commonHeaders)
 
@@ -1,22 +1,117 @@
 package main
 
 import (
+	"cmp"
+	"fmt"
 	"html/template"
 	"io/fs"
 	"path/filepath"
+	"strings"
 	"time"
+	"vtorosyan.learning/internal/diff"
+	"vtorosyan.learning/internal/highlight"
+	"vtorosyan.learning/internal/markdown"
 	"vtorosyan.learning/internal/models"
 	"vtorosyan.learning/ui"
 )
 
 type templateData struct {
-	CurrentYear     int
-	Snippet         models.Snippet
-	Snippets        []models.Snippet
-	Flash           string
-	Form            any
-	IsAuthenticated bool
-	CSRFToken       string
+	CurrentYear         int
+	Snippet             models.Snippet
+	Snippets            []models.Snippet
+	Parent              *models.Snippet
+	Forks               []models.Snippet
+	Starred             bool
+	MostStarred         []models.Snippet
+	Comments            []commentThread
+	Source              bool
+	Page                models.SnippetPage
+	Sort                models.SnippetOrder
+	Tag                 string
+	Query               string
+	Results             []models.SearchResult
+	Revisions           []models.Revision
+	Diff                *snippetDiff
+	Tokens              []models.Token
+	NewToken            string
+	Flash               string
+	Form                any
+	ExpiryPresets       []int
+	IsAuthenticated     bool
+	AuthenticatedUserID int
+	CSRFToken           string
+}
+
+// commentThread is a comment on a snippet with the replies to it, oldest
+// first.
+type commentThread struct {
+	models.Comment
+	Replies []models.Comment
+}
+
+// commentThreads groups comments, oldest first, into threads. A reply whose
+// parent is missing starts a thread of its own.
+func commentThreads(comments []models.Comment) []commentThread {
+	var threads []commentThread
+	index := make(map[int]int)
+	for _, comment := range comments {
+		if i, ok := index[comment.ParentID]; ok && comment.ParentID != 0 {
+			threads[i].Replies = append(threads[i].Replies, comment)
+			continue
+		}
+		index[comment.ID] = len(threads)
+		threads = append(threads, commentThread{Comment: comment})
+	}
+	return threads
+}
+
+// snippetDiff is a comparison of two revisions of a snippet, file by file.
+type snippetDiff struct {
+	From  models.Revision
+	To    models.Revision
+	Files []fileDiff
+}
+
+// fileDiff compares the files at the same position in two revisions. OldName
+// is set when the file was renamed, and Added or Removed when only one of the
+// revisions has a file there.
+type fileDiff struct {
+	Name    string
+	OldName string
+	Added   bool
+	Removed bool
+	Changed bool
+	Lines   []diff.Line
+}
+
+// newSnippetDiff compares two revisions of a snippet, pairing their files by
+// position.
+func newSnippetDiff(from, to models.Revision) *snippetDiff {
+	d := &snippetDiff{From: from, To: to}
+	for i := range max(len(from.Files), len(to.Files)) {
+		var old, cur models.File
+		var f fileDiff
+		switch {
+		case i >= len(from.Files):
+			cur, f.Added = to.Files[i], true
+		case i >= len(to.Files):
+			old, f.Removed = from.Files[i], true
+		default:
+			old, cur = from.Files[i], to.Files[i]
+		}
+
+		oldName := cmp.Or(old.Name, fmt.Sprintf("File %d", i+1))
+		f.Name = cmp.Or(cur.Name, fmt.Sprintf("File %d", i+1))
+		if f.Removed {
+			f.Name = oldName
+		} else if !f.Added && oldName != f.Name {
+			f.OldName = oldName
+		}
+		f.Changed = f.Added || f.Removed || old != cur
+		f.Lines = diff.Lines(old.Content, cur.Content)
+		d.Files = append(d.Files, f)
+	}
+	return d
 }
 
 func humanDate(t time.Time) string {
@@ -26,8 +121,87 @@ func humanDate(t time.Time) string {
 	return t.Format("02 Jan 2006 at 15:04")
 }
 
+// fileLanguageID is the language a file of a snippet is highlighted as, which
+// is detected from its content when the author did not pick one. The content
+// of encrypted snippets is ciphertext, so there is nothing to detect.
+func fileLanguageID(snippet models.Snippet, file models.File) string {
+	if snippet.ContentType == models.ContentMarkdown {
+		return "markdown"
+	}
+	if file.Language == "" && !snippet.Encrypted {
+		return highlight.Detect(file.Content)
+	}
+	return file.Language
+}
+
+// snippetLanguageID is the language the first file of a snippet is
+// highlighted as.
+func snippetLanguageID(snippet models.Snippet) string {
+	return fileLanguageID(snippet, models.File{Language: snippet.Language, Content: snippet.Content})
+}
+
+// snippetLanguage names the language the first file of a snippet is
+// highlighted as.
+func snippetLanguage(snippet models.Snippet) string {
+	return highlight.Name(snippetLanguageID(snippet))
+}
+
+// fileLanguage names the language a file of a snippet is highlighted as.
+func fileLanguage(snippet models.Snippet, file models.File) string {
+	return highlight.Name(fileLanguageID(snippet, file))
+}
+
+// fileNumber is the number, counted from 1, of the file at index i of a
+// snippet.
+func fileNumber(i int) int {
+	return i + 1
+}
+
+// linePrefix starts the line anchors of the file at index i of a snippet. The
+// first file keeps the plain L1, L2... anchors so that links made before
+// snippets had several files still work; the second one has F2-L1 and so on.
+func linePrefix(i int) string {
+	if i == 0 {
+		return "L"
+	}
+	return fmt.Sprintf("F%d-L", i+1)
+}
+
+// fileName picks the name of the file numbered number out of names, "" when
+// there is no such file, for example after it was removed from the snippet.
+func fileName(names []string, number int) string {
+	if number < 1 || number > len(names) {
+		return ""
+	}
+	return names[number-1]
+}
+
+// lineAnchor is the id of a line of the file numbered file, as set by
+// highlightFile.
+func lineAnchor(file int, line int) string {
+	return fmt.Sprintf("%s%d", linePrefix(file-1), line)
+}
+
+// highlightFile highlights the file at index i of a snippet with its own line
+// anchors.
+func highlightFile(language string, content string, i int) (template.HTML, error) {
+	return highlight.HTMLWithPrefix(language, content, linePrefix(i))
+}
+
 var functions = template.FuncMap{
-	"humanDate": humanDate,
+	"humanDate":       humanDate,
+	"join":            strings.Join,
+	"highlightFile":   highlightFile,
+	"markdown":        markdown.Render,
+	"languages":       func() []highlight.Language { return highlight.Languages },
+	"snippetLanguage": snippetLanguage,
+	"fileLanguage":    fileLanguage,
+	"fileLanguageID":  fileLanguageID,
+	"filenames":       snippetFilenames,
+	"fileNumber":      fileNumber,
+	"fileName":        fileName,
+	"lineAnchor":      lineAnchor,
+	"expiryLabel":     expiryLabel,
 }
 
 func newTemplateCache() (map[string]*template.Template, error) {
@@ -0,0 +1,179 @@
+// Package diff computes line-by-line differences between two texts using
+// Myers' O(ND) algorithm.
+package diff
+
+import (
+	"slices"
+	"strings"
+)
+
+type Op int
+
+const (
+	Equal Op = iota
+	Insert
+	Delete
+)
+
+func (op Op) String() string {
+	switch op {
+	case Insert:
+		return "insert"
+	case Delete:
+		return "delete"
+	default:
+		return "equal"
+	}
+}
+
+// Line is one line of a diff. OldNumber and NewNumber are 1-based line
+// numbers in the old and new text, zero when the line is absent from it.
+type Line struct {
+	Op        Op
+	Text      string
+	OldNumber int
+	NewNumber int
+}
+
+// Lines returns the shortest edit script turning old into new, line by line.
+// Windows line endings are treated like Unix ones.
+func Lines(old, new string) []Line {
+	lines := myers(split(old), split(new))
+
+	oldNumber, newNumber := 0, 0
+	for i := range lines {
+		switch lines[i].Op {
+		case Equal:
+			oldNumber++
+			newNumber++
+			lines[i].OldNumber, lines[i].NewNumber = oldNumber, newNumber
+		case Delete:
+			oldNumber++
+			lines[i].OldNumber = oldNumber
+		case Insert:
+			newNumber++
+			lines[i].NewNumber = newNumber
+		}
+	}
+
+	return lines
+}
+
+func split(s string) []string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	s = strings.TrimSuffix(s, "\n")
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
+
+// maxEdits bounds the work done for texts that have little in common. Beyond
+// it the differing middle is reported as replaced wholesale.
+const maxEdits = 1000
+
+// myers trims the common prefix and suffix of a and b and diffs what is left.
+func myers(a, b []string) []Line {
+	var prefix, suffix []Line
+	for len(a) > 0 && len(b) > 0 && a[0] == b[0] {
+		prefix = append(prefix, Line{Op: Equal, Text: a[0]})
+		a, b = a[1:], b[1:]
+	}
+	for len(a) > 0 && len(b) > 0 && a[len(a)-1] == b[len(b)-1] {
+		suffix = append(suffix, Line{Op: Equal, Text: a[len(a)-1]})
+		a, b = a[:len(a)-1], b[:len(b)-1]
+	}
+	slices.Reverse(suffix)
+
+	middle, ok := shortestEdit(a, b)
+	if !ok {
+		middle = nil
+		for _, text := range a {
+			middle = append(middle, Line{Op: Delete, Text: text})
+		}
+		for _, text := range b {
+			middle = append(middle, Line{Op: Insert, Text: text})
+		}
+	}
+
+	return slices.Concat(prefix, middle, suffix)
+}
+
+// shortestEdit walks the edit graph of a and b one edit distance d at a time,
+// recording the furthest reaching x on every diagonal k = x - y, and then
+// backtracks through the recorded rounds to recover the edits. It gives up
+// after maxEdits rounds.
+func shortestEdit(a, b []string) ([]Line, bool) {
+	n, m := len(a), len(b)
+	limit := min(n+m, maxEdits)
+	offset := limit + 1
+	v := make([]int, 2*limit+3)
+
+	// trace[d] holds the diagonals -d-1..d+1 of v as they were before round
+	// d, which is all backtracking needs from that round.
+	var trace [][]int
+	for d := 0; d <= limit; d++ {
+		trace = append(trace, slices.Clone(v[offset-d-1:offset+d+2]))
+
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+
+			if x >= n && y >= m {
+				return backtrack(trace, a, b), true
+			}
+		}
+	}
+
+	return nil, false
+}
+
+func backtrack(trace [][]int, a, b []string) []Line {
+	var lines []Line
+	x, y := len(a), len(b)
+
+	for d := len(trace) - 1; d >= 0; d-- {
+		window := trace[d]
+		v := func(k int) int { return window[k+d+1] }
+		k := x - y
+
+		var prevK int
+		if k == -d || (k != d && v(k-1) < v(k+1)) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v(prevK)
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			lines = append(lines, Line{Op: Equal, Text: a[x-1]})
+			x--
+			y--
+		}
+
+		if d > 0 {
+			if x == prevX {
+				lines = append(lines, Line{Op: Insert, Text: b[y-1]})
+			} else {
+				lines = append(lines, Line{Op: Delete, Text: a[x-1]})
+			}
+		}
+
+		x, y = prevX, prevY
+	}
+
+	slices.Reverse(lines)
+	return lines
+}
@@ -0,0 +1,74 @@
+package diff
+
+import (
+	"strings"
+	"testing"
+	"vtorosyan.learning/internal/assert"
+)
+
+func render(lines []Line) string {
+	var b strings.Builder
+	for _, l := range lines {
+		switch l.Op {
+		case Equal:
+			b.WriteString(" ")
+		case Insert:
+			b.WriteString("+")
+		case Delete:
+			b.WriteString("-")
+		}
+		b.WriteString(l.Text)
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+func TestLines(t *testing.T) {
+	tests := []struct {
+		name string
+		old  string
+		new  string
+		want string
+	}{
+		{name: "Identical", old: "a\nb\n", new: "a\nb", want: " a\n b\n"},
+		{name: "Empty", old: "", new: "", want: ""},
+		{name: "All new", old: "", new: "a\nb", want: "+a\n+b\n"},
+		{name: "All gone", old: "a\nb", new: "", want: "-a\n-b\n"},
+		{name: "Change in the middle", old: "a\nb\nc", new: "a\nx\nc", want: " a\n-b\n+x\n c\n"},
+		{name: "CRLF", old: "a\r\nb", new: "a\nb\nc", want: " a\n b\n+c\n"},
+		{name: "Classic", old: "A\nB\nC\nA\nB\nB\nA", new: "C\nB\nA\nB\nA\nC", want: "-A\n-B\n C\n+B\n A\n B\n-B\n A\n+C\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, render(Lines(tt.old, tt.new)), tt.want)
+		})
+	}
+}
+
+func TestLineNumbers(t *testing.T) {
+	lines := Lines("a\nb\nc", "a\nx\nc")
+
+	assert.Equal(t, len(lines), 4)
+	assert.Equal(t, lines[1].OldNumber, 2)
+	assert.Equal(t, lines[1].NewNumber, 0)
+	assert.Equal(t, lines[2].OldNumber, 0)
+	assert.Equal(t, lines[2].NewNumber, 2)
+	assert.Equal(t, lines[3].OldNumber, 3)
+	assert.Equal(t, lines[3].NewNumber, 3)
+}
+
+func TestLinesGivesUp(t *testing.T) {
+	var old, new strings.Builder
+	for i := 0; i < maxEdits; i++ {
+		old.WriteString("old\n")
+		new.WriteString("new\n")
+	}
+
+	lines := Lines("same\n"+old.String()+"same", "same\n"+new.String()+"same")
+
+	assert.Equal(t, len(lines), 2*maxEdits+2)
+	assert.Equal(t, lines[1].Op, Delete)
+	assert.Equal(t, lines[maxEdits+1].Op, Insert)
+	assert.Equal(t, lines[len(lines)-1].Op, Equal)
+}
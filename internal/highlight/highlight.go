@@ -0,0 +1,175 @@
+// Package highlight renders snippet content as syntax highlighted HTML on the
+// server, so that no script is needed in the browser.
+package highlight
+
+import (
+	"bytes"
+	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+	"html/template"
+	"sync"
+)
+
+// Language is one of the languages a snippet can be written in. ID is what
+// gets stored and is also a chroma lexer alias. Ext is the usual file name
+// extension.
+type Language struct {
+	ID   string
+	Name string
+	Ext  string
+}
+
+// Languages are the languages offered when creating a snippet, in the order
+// they are listed.
+var Languages = []Language{
+	{"bash", "Bash", ".sh"},
+	{"c", "C", ".c"},
+	{"cpp", "C++", ".cpp"},
+	{"csharp", "C#", ".cs"},
+	{"css", "CSS", ".css"},
+	{"diff", "Diff", ".diff"},
+	{"docker", "Dockerfile", ".dockerfile"},
+	{"go", "Go", ".go"},
+	{"html", "HTML", ".html"},
+	{"java", "Java", ".java"},
+	{"javascript", "JavaScript", ".js"},
+	{"json", "JSON", ".json"},
+	{"kotlin", "Kotlin", ".kt"},
+	{"markdown", "Markdown", ".md"},
+	{"php", "PHP", ".php"},
+	{"python", "Python", ".py"},
+	{"ruby", "Ruby", ".rb"},
+	{"rust", "Rust", ".rs"},
+	{"sql", "SQL", ".sql"},
+	{"swift", "Swift", ".swift"},
+	{"toml", "TOML", ".toml"},
+	{"typescript", "TypeScript", ".ts"},
+	{"yaml", "YAML", ".yaml"},
+}
+
+// IDs returns the ids of all Languages.
+func IDs() []string {
+	ids := make([]string, len(Languages))
+	for i, language := range Languages {
+		ids[i] = language.ID
+	}
+	return ids
+}
+
+// Name returns the display name of a language id, or "Plain text" for
+// anything unknown, including the empty id.
+func Name(id string) string {
+	for _, language := range Languages {
+		if language.ID == id {
+			return language.Name
+		}
+	}
+	return "Plain text"
+}
+
+// Ext returns the file name extension for a language id, or ".txt" for
+// anything unknown.
+func Ext(id string) string {
+	for _, language := range Languages {
+		if language.ID == id {
+			return language.Ext
+		}
+	}
+	return ".txt"
+}
+
+// Detect guesses the language of content, returning "" when it cannot tell
+// or the guess is not one of Languages.
+func Detect(content string) string {
+	lexer := lexers.Analyse(content)
+	if lexer == nil {
+		return ""
+	}
+
+	for _, language := range Languages {
+		if l := lexers.Get(language.ID); l != nil && l.Config().Name == lexer.Config().Name {
+			return language.ID
+		}
+	}
+	return ""
+}
+
+var (
+	formatter      = lineFormatter("L")
+	blockFormatter = html.New(html.WithClasses(true), html.TabWidth(4))
+)
+
+// lineFormatter numbers every line, linking the numbers to anchors named
+// prefix followed by the line number.
+func lineFormatter(prefix string) *html.Formatter {
+	return html.New(
+		html.WithClasses(true),
+		html.WithLineNumbers(true),
+		html.WithLinkableLineNumbers(true, prefix),
+		html.TabWidth(4),
+	)
+}
+
+var style = styles.Get("github")
+
+// HTML tokenises content as the given language, detecting it when id is
+// empty, and returns it as highlighted HTML. Every line is numbered, and the
+// numbers link to anchors named L1, L2 and so on.
+func HTML(id string, content string) (template.HTML, error) {
+	return format(formatter, id, content)
+}
+
+// HTMLWithPrefix is like HTML with the anchors named prefix followed by the
+// line number instead, so that several files on one page each have their own.
+func HTMLWithPrefix(id string, content string, prefix string) (template.HTML, error) {
+	if prefix == "L" {
+		return HTML(id, content)
+	}
+	return format(lineFormatter(prefix), id, content)
+}
+
+// Block is like HTML without the line numbers, for code blocks embedded in
+// a larger document. id may be any alias the highlighter knows, not just one
+// of Languages.
+func Block(id string, content string) (template.HTML, error) {
+	return format(blockFormatter, id, content)
+}
+
+func format(f *html.Formatter, id string, content string) (template.HTML, error) {
+	if id == "" {
+		id = Detect(content)
+	}
+
+	lexer := lexers.Get(id)
+	if lexer == nil {
+		lexer = lexers.Fallback
+	}
+	lexer = chroma.Coalesce(lexer)
+
+	iterator, err := lexer.Tokenise(nil, content)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	err = f.Format(&buf, style, iterator)
+	if err != nil {
+		return "", err
+	}
+
+	// The formatter escapes every token it writes.
+	return template.HTML(buf.String()), nil
+}
+
+var css = sync.OnceValues(func() ([]byte, error) {
+	var buf bytes.Buffer
+	err := formatter.WriteCSS(&buf, style)
+	return buf.Bytes(), err
+})
+
+// CSS returns the stylesheet for the classes used in the HTML output.
+func CSS() ([]byte, error) {
+	return css()
+}
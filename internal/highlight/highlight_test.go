@@ -0,0 +1,51 @@
+package highlight
+
+import (
+	"strings"
+	"testing"
+	"vtorosyan.learning/internal/assert"
+)
+
+func TestHTML(t *testing.T) {
+	out, err := HTML("go", "package main\n\nfunc main() {\n\tprintln(\"<b>\")\n}\n")
+	assert.NilError(t, err)
+
+	html := string(out)
+	assert.StringContains(t, html, `<span class="ln" id="L3"><a class="lnlinks" href="#L3">3</a></span>`)
+	assert.StringContains(t, html, `<span class="kd">func</span>`)
+	assert.StringContains(t, html, `&lt;b&gt;`)
+	assert.Equal(t, strings.Contains(html, "<b>"), false)
+	assert.Equal(t, strings.Contains(html, "style="), false)
+
+	out, err = HTMLWithPrefix("go", "package main\n", "F2-L")
+	assert.NilError(t, err)
+	assert.StringContains(t, string(out), `<span class="ln" id="F2-L1"><a class="lnlinks" href="#F2-L1">1</a></span>`)
+
+	out, err = HTML("no-such-language", "<script>alert(1)</script>")
+	assert.NilError(t, err)
+	assert.StringContains(t, string(out), "&lt;script&gt;")
+}
+
+func TestDetect(t *testing.T) {
+	assert.Equal(t, Detect("#!/bin/bash\necho hello\n"), "bash")
+	assert.Equal(t, Detect("just some words"), "")
+
+	out, err := HTML("", "#!/bin/bash\necho hello\n")
+	assert.NilError(t, err)
+	assert.StringContains(t, string(out), `<span class="nb">echo</span>`)
+}
+
+func TestName(t *testing.T) {
+	assert.Equal(t, Name("cpp"), "C++")
+	assert.Equal(t, Name(""), "Plain text")
+
+	for _, id := range IDs() {
+		assert.Equal(t, Name(id) != "Plain text", true)
+	}
+}
+
+func TestCSS(t *testing.T) {
+	css, err := CSS()
+	assert.NilError(t, err)
+	assert.StringContains(t, string(css), ".chroma .ln:target")
+}
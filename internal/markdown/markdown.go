@@ -0,0 +1,127 @@
+// Package markdown renders Markdown snippets to HTML which is safe to embed
+// in a page, whatever the snippet contains.
+package markdown
+
+import (
+	"bytes"
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/util"
+	"html/template"
+	"regexp"
+	"vtorosyan.learning/internal/highlight"
+)
+
+// anchorPrefix starts the id of every heading, so that headings cannot clash
+// with the ids used by the rest of the page.
+const anchorPrefix = "md-"
+
+var md = goldmark.New(
+	goldmark.WithExtensions(
+		extension.NewTable(extension.WithTableCellAlignMethod(extension.TableCellAlignAttribute)),
+		extension.Strikethrough,
+		extension.Linkify,
+		extension.TaskList,
+	),
+	goldmark.WithParserOptions(parser.WithAutoHeadingID()),
+	goldmark.WithRendererOptions(
+		renderer.WithNodeRenderers(util.Prioritized(nodeRenderer{}, 100)),
+	),
+)
+
+// policy is the sanitiser every rendered document goes through. goldmark
+// already drops raw HTML and dangerous link targets; this is the second line
+// of defence, and also strips inline styles, which the Content-Security-Policy
+// would block anyway.
+var policy = func() *bluemonday.Policy {
+	p := bluemonday.UGCPolicy()
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^[a-z0-9 -]+$`)).OnElements("a", "code", "pre", "span")
+	p.AllowAttrs("id").Matching(regexp.MustCompile(`^`+anchorPrefix+`[A-Za-z0-9_-]+$`)).
+		OnElements("h1", "h2", "h3", "h4", "h5", "h6")
+	p.AllowAttrs("align").Matching(regexp.MustCompile(`^(left|center|right)$`)).OnElements("th", "td")
+	p.AllowAttrs("type").Matching(regexp.MustCompile(`^checkbox$`)).OnElements("input")
+	p.AllowAttrs("checked", "disabled").OnElements("input")
+	return p
+}()
+
+// Render converts Markdown source to sanitised HTML. It supports GitHub style
+// tables, strikethrough, task lists and fenced code blocks, which are syntax
+// highlighted. Every heading gets an anchor linking to itself.
+func Render(source string) (template.HTML, error) {
+	var buf bytes.Buffer
+	err := md.Convert([]byte(source), &buf)
+	if err != nil {
+		return "", err
+	}
+
+	return template.HTML(policy.SanitizeReader(&buf).String()), nil
+}
+
+// nodeRenderer replaces goldmark's renderers for headings and fenced code.
+type nodeRenderer struct{}
+
+func (nodeRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(ast.KindHeading, renderHeading)
+	reg.Register(ast.KindFencedCodeBlock, renderFencedCodeBlock)
+}
+
+func renderHeading(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	n := node.(*ast.Heading)
+	level := "0123456"[n.Level]
+
+	var id []byte
+	if value, ok := n.AttributeString("id"); ok {
+		if b, ok := value.([]byte); ok {
+			id = util.EscapeHTML(append([]byte(anchorPrefix), b...))
+		}
+	}
+
+	if entering {
+		w.WriteString("<h")
+		w.WriteByte(level)
+		if id != nil {
+			w.WriteString(` id="`)
+			w.Write(id)
+			w.WriteByte('"')
+		}
+		w.WriteByte('>')
+		return ast.WalkContinue, nil
+	}
+
+	if id != nil {
+		w.WriteString(` <a class="anchor" href="#`)
+		w.Write(id)
+		w.WriteString(`">#</a>`)
+	}
+	w.WriteString("</h")
+	w.WriteByte(level)
+	w.WriteString(">\n")
+	return ast.WalkContinue, nil
+}
+
+func renderFencedCodeBlock(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+
+	n := node.(*ast.FencedCodeBlock)
+
+	var code bytes.Buffer
+	lines := n.Lines()
+	for i := 0; i < lines.Len(); i++ {
+		line := lines.At(i)
+		code.Write(line.Value(source))
+	}
+
+	html, err := highlight.Block(string(n.Language(source)), code.String())
+	if err != nil {
+		return ast.WalkStop, err
+	}
+	w.WriteString(string(html))
+
+	return ast.WalkSkipChildren, nil
+}
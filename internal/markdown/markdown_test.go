@@ -0,0 +1,31 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+	"vtorosyan.learning/internal/assert"
+)
+
+func TestRender(t *testing.T) {
+	source := "# Notes\n\n" +
+		"| Name | Size |\n|:-----|-----:|\n| a | 1 |\n\n" +
+		"```go\nfunc main() {}\n```\n\n" +
+		"- [x] done\n\n" +
+		"<script>alert(1)</script>\n\n" +
+		"<b onclick='x()'>bold</b> [link](javascript:alert(1)) [ok](https://example.com)\n"
+
+	out, err := Render(source)
+	assert.NilError(t, err)
+	html := string(out)
+
+	assert.StringContains(t, html, `<h1 id="md-notes">Notes <a class="anchor" href="#md-notes" rel="nofollow">#</a></h1>`)
+	assert.StringContains(t, html, `<th align="left">Name</th>`)
+	assert.StringContains(t, html, `<td align="right">1</td>`)
+	assert.StringContains(t, html, `<pre class="chroma"><code><span class="line"><span class="cl"><span class="kd">func</span>`)
+	assert.StringContains(t, html, `<input checked="" disabled="" type="checkbox">`)
+	assert.StringContains(t, html, `href="https://example.com"`)
+
+	for _, unsafe := range []string{"<script", "alert(1)</script>", "onclick", "javascript:", "style="} {
+		assert.Equal(t, strings.Contains(html, unsafe), false)
+	}
+}
@@ -0,0 +1,128 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Comment is a signed-in user's remark on a snippet, optionally about one line
+// of one of its files, or a reply to another comment on the same snippet.
+type Comment struct {
+	ID         int
+	SnippetID  int
+	UserID     int
+	AuthorName string
+	// ParentID is the id of the comment this one replies to, 0 if it does
+	// not. Deleting a comment deletes its replies.
+	ParentID int
+	// File is the number, counted from 1, of the file holding Line, and
+	// Line the line number the comment is about. Both are 0 for the whole
+	// snippet.
+	File    int
+	Line    int
+	Body    string
+	Created time.Time
+}
+
+// CommentStore is the storage-agnostic set of comment operations the web
+// application depends on.
+type CommentStore interface {
+	Insert(snippetID, userID, parentID, file, line int, body string) (int, error)
+	Get(id int) (Comment, error)
+	ForSnippet(snippetID int) ([]Comment, error)
+	Delete(id, userID int) error
+}
+
+type CommentModel struct {
+	DB      *sql.DB
+	Dialect Dialect
+}
+
+// commentColumns and commentTables are shared by the comment queries so that
+// scanComment can read the rows, including the author's name.
+const (
+	commentColumns = `c.id, c.snippet_id, c.user_id, COALESCE(u.name, ''), c.parent_id, c.file, c.line, c.body, c.created`
+	commentTables  = `comments c LEFT JOIN users u ON u.id = c.user_id`
+)
+
+func scanComment(row scanner) (Comment, error) {
+	var comment Comment
+	var parentID sql.NullInt64
+	err := row.Scan(&comment.ID, &comment.SnippetID, &comment.UserID, &comment.AuthorName,
+		&parentID, &comment.File, &comment.Line, &comment.Body, &comment.Created)
+	comment.ParentID = int(parentID.Int64)
+	return comment, err
+}
+
+func (m *CommentModel) Insert(snippetID, userID, parentID, file, line int, body string) (int, error) {
+	stmt := `INSERT INTO comments (snippet_id, user_id, parent_id, file, line, body, created)
+VALUES (?, ?, ?, ?, ?, ?, ` + m.Dialect.Now() + `)`
+
+	parent := sql.NullInt64{Int64: int64(parentID), Valid: parentID != 0}
+	return insert(m.DB, m.Dialect, stmt, snippetID, userID, parent, file, line, body)
+}
+
+func (m *CommentModel) Get(id int) (Comment, error) {
+	query := `SELECT ` + commentColumns + ` FROM ` + commentTables + ` WHERE c.id = ?`
+
+	comment, err := scanComment(m.DB.QueryRow(m.Dialect.Rebind(query), id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Comment{}, ErrNoRecord
+		}
+		return Comment{}, err
+	}
+
+	return comment, nil
+}
+
+// ForSnippet returns the comments on a snippet, oldest first.
+func (m *CommentModel) ForSnippet(snippetID int) ([]Comment, error) {
+	query := `SELECT ` + commentColumns + ` FROM ` + commentTables + `
+WHERE c.snippet_id = ? ORDER BY c.created, c.id`
+
+	rows, err := m.DB.Query(m.Dialect.Rebind(query), snippetID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var comments []Comment
+
+	for rows.Next() {
+		comment, err := scanComment(rows)
+		if err != nil {
+			return nil, err
+		}
+		comments = append(comments, comment)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return comments, nil
+}
+
+// Delete removes a comment written by userID or made on a snippet userID
+// owns, along with the replies to it.
+func (m *CommentModel) Delete(id, userID int) error {
+	stmt := `DELETE FROM comments WHERE id = ? AND (user_id = ? OR snippet_id IN (SELECT id FROM snippets WHERE user_id = ?))`
+
+	rslt, err := m.DB.Exec(m.Dialect.Rebind(stmt), id, userID, userID)
+	if err != nil {
+		return err
+	}
+
+	n, err := rslt.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
@@ -0,0 +1,79 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"vtorosyan.learning/internal/assert"
+)
+
+func testComments(t *testing.T, snippets SnippetStore, m CommentStore) {
+	files := []File{{Content: "one\ntwo"}, {Name: "notes", Content: "three"}}
+	snippetID, err := snippets.Insert(1, SnippetInput{Title: "Hello", Files: files, Expires: 7})
+	assert.NilError(t, err)
+
+	first, err := m.Insert(snippetID, 2, 0, 0, 0, "Nice snippet")
+	assert.NilError(t, err)
+	second, err := m.Insert(snippetID, 3, 0, 2, 1, "Typo on this line")
+	assert.NilError(t, err)
+	_, err = m.Insert(snippetID, 2, 0, 1, 1, "Why?")
+	assert.NilError(t, err)
+	reply, err := m.Insert(snippetID, 1, first, 0, 0, "Thanks!")
+	assert.NilError(t, err)
+
+	comment, err := m.Get(second)
+	assert.NilError(t, err)
+	assert.Equal(t, comment.SnippetID, snippetID)
+	assert.Equal(t, comment.AuthorName, "Carol")
+	assert.Equal(t, comment.File, 2)
+	assert.Equal(t, comment.Line, 1)
+	assert.Equal(t, comment.ParentID, 0)
+	assert.Equal(t, comment.Body, "Typo on this line")
+
+	comment, err = m.Get(reply)
+	assert.NilError(t, err)
+	assert.Equal(t, comment.ParentID, first)
+
+	_, err = m.Get(99)
+	assert.Equal(t, errors.Is(err, ErrNoRecord), true)
+
+	comments, err := m.ForSnippet(snippetID)
+	assert.NilError(t, err)
+	assert.Equal(t, len(comments), 4)
+	assert.Equal(t, comments[0].ID, first)
+	assert.Equal(t, comments[0].AuthorName, "Bob")
+
+	// Only the author and the snippet's owner can delete a comment, which
+	// deletes the replies to it too.
+	err = m.Delete(first, 3)
+	assert.Equal(t, errors.Is(err, ErrNoRecord), true)
+	assert.NilError(t, m.Delete(first, 2))
+	assert.NilError(t, m.Delete(second, 1))
+
+	_, err = m.Get(reply)
+	assert.Equal(t, errors.Is(err, ErrNoRecord), true)
+
+	comments, err = m.ForSnippet(snippetID)
+	assert.NilError(t, err)
+	assert.Equal(t, len(comments), 1)
+	assert.Equal(t, comments[0].Body, "Why?")
+}
+
+func TestCommentModel(t *testing.T) {
+	db := newTestDB(t)
+	users := UserModel{DB: db, Dialect: SQLite}
+	for _, name := range []string{"Alice", "Bob", "Carol"} {
+		assert.NilError(t, users.Insert(name, name+"@example.com", "pa$$word"))
+	}
+
+	testComments(t, &SnippetModel{DB: db, Dialect: SQLite}, &CommentModel{DB: db, Dialect: SQLite})
+}
+
+func TestMemoryCommentModel(t *testing.T) {
+	users := NewMemoryUserModel()
+	for _, name := range []string{"Alice", "Bob", "Carol"} {
+		assert.NilError(t, users.Insert(name, name+"@example.com", "pa$$word"))
+	}
+	snippets := NewMemorySnippetModel(users)
+
+	testComments(t, snippets, NewMemoryCommentModel(snippets, users))
+}
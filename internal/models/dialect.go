@@ -0,0 +1,219 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Dialect hides the differences between the SQL databases the models can run
+// on. Queries are written once with ? placeholders and ask the dialect for the
+// database specific fragments.
+type Dialect interface {
+	// DriverName is the database/sql driver the dialect expects.
+	DriverName() string
+	// Now is an expression for the current UTC timestamp.
+	Now() string
+	// AddDays is an expression for the UTC timestamp n days from now, where n
+	// is itself an SQL expression, usually a placeholder.
+	AddDays(n string) string
+	// IsDuplicate reports whether err is a violation of the named unique
+	// constraint.
+	IsDuplicate(err error, constraint string) bool
+	// Rebind rewrites the ? placeholders in query into the dialect's own
+	// placeholder syntax.
+	Rebind(query string) string
+	// Time converts t into a query argument that compares correctly with the
+	// timestamps the dialect stores.
+	Time(t time.Time) any
+	// Returning reports whether generated ids have to be read back with a
+	// RETURNING clause because the driver does not support LastInsertId.
+	Returning() bool
+	// FullText returns a query selecting the id and a relevance score, higher
+	// being better, of every snippet matching all of the search terms, along
+	// with its arguments. Terms only hold letters, digits and underscores and
+	// match as word prefixes.
+	FullText(terms []string) (string, []any)
+	// InsertOrIgnore rewrites an INSERT statement so that rows which would
+	// violate a unique constraint are silently skipped.
+	InsertOrIgnore(stmt string) string
+}
+
+var (
+	MySQL    Dialect = mysqlDialect{}
+	SQLite   Dialect = sqliteDialect{}
+	Postgres Dialect = postgresDialect{}
+)
+
+// dbtx is the part of *sql.DB and *sql.Tx the models need.
+type dbtx interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// insert runs an INSERT statement and returns the id of the new row.
+func insert(db dbtx, d Dialect, stmt string, args ...any) (int, error) {
+	if d.Returning() {
+		var id int
+		err := db.QueryRow(d.Rebind(stmt+" RETURNING id"), args...).Scan(&id)
+		return id, err
+	}
+
+	rslt, err := db.Exec(d.Rebind(stmt), args...)
+	if err != nil {
+		return 0, err
+	}
+	id, err := rslt.LastInsertId()
+	return int(id), err
+}
+
+type mysqlDialect struct{}
+
+func (mysqlDialect) DriverName() string { return "mysql" }
+
+func (mysqlDialect) Now() string { return "UTC_TIMESTAMP()" }
+
+func (mysqlDialect) AddDays(n string) string {
+	return "DATE_ADD(UTC_TIMESTAMP(), INTERVAL " + n + " DAY)"
+}
+
+func (mysqlDialect) Rebind(query string) string { return query }
+
+func (mysqlDialect) Time(t time.Time) any { return t.UTC() }
+
+func (mysqlDialect) Returning() bool { return false }
+
+func (mysqlDialect) InsertOrIgnore(stmt string) string {
+	return strings.Replace(stmt, "INSERT", "INSERT IGNORE", 1)
+}
+
+// FullText uses the FULLTEXT index on title and files_content in boolean
+// mode, where a + makes every term required and a trailing * matches
+// prefixes.
+func (mysqlDialect) FullText(terms []string) (string, []any) {
+	against := "+" + strings.Join(terms, "* +") + "*"
+	query := `SELECT id, MATCH(title, files_content) AGAINST (? IN BOOLEAN MODE) AS score FROM snippets
+WHERE MATCH(title, files_content) AGAINST (? IN BOOLEAN MODE)`
+	return query, []any{against, against}
+}
+
+func (mysqlDialect) IsDuplicate(err error, constraint string) bool {
+	var mySQLErr *mysql.MySQLError
+	if errors.As(err, &mySQLErr) {
+		return mySQLErr.Number == 1062 && strings.Contains(mySQLErr.Message, constraint)
+	}
+	return false
+}
+
+// SQLite reports unique violations by column rather than by constraint name,
+// so the constraints the models care about are mapped to their columns here.
+var sqliteUniqueColumns = map[string]string{
+	"users_uc_email": "users.email",
+}
+
+type sqliteDialect struct{}
+
+func (sqliteDialect) DriverName() string { return "sqlite" }
+
+// Timestamps are stored as text in a layout the driver parses back into
+// time.Time and which sorts and compares correctly as a string.
+func (sqliteDialect) Now() string { return "strftime('%Y-%m-%d %H:%M:%f', 'now')" }
+
+func (sqliteDialect) AddDays(n string) string {
+	return "strftime('%Y-%m-%d %H:%M:%f', 'now', '+' || " + n + " || ' days')"
+}
+
+func (sqliteDialect) Rebind(query string) string { return query }
+
+// Time formats t exactly like Now, so that equal instants compare equal as
+// strings.
+func (sqliteDialect) Time(t time.Time) any { return t.UTC().Format("2006-01-02 15:04:05.000") }
+
+func (sqliteDialect) Returning() bool { return false }
+
+func (sqliteDialect) InsertOrIgnore(stmt string) string {
+	return strings.Replace(stmt, "INSERT", "INSERT OR IGNORE", 1)
+}
+
+// FullText queries the snippets_fts FTS5 table. bm25 is lower for better
+// matches, so it is negated, and hits in the title weigh more than in content.
+func (sqliteDialect) FullText(terms []string) (string, []any) {
+	match := `"` + strings.Join(terms, `"* "`) + `"*`
+	query := `SELECT rowid AS id, -bm25(snippets_fts, 10.0, 1.0) AS score FROM snippets_fts
+WHERE snippets_fts MATCH ?`
+	return query, []any{match}
+}
+
+func (sqliteDialect) IsDuplicate(err error, constraint string) bool {
+	column, ok := sqliteUniqueColumns[constraint]
+	if !ok {
+		return false
+	}
+
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE && strings.Contains(sqliteErr.Error(), column)
+	}
+	return false
+}
+
+type postgresDialect struct{}
+
+func (postgresDialect) DriverName() string { return "pgx" }
+
+// Timestamps are stored in TIMESTAMP columns holding UTC, matching the other
+// dialects.
+func (postgresDialect) Now() string { return "(NOW() AT TIME ZONE 'UTC')" }
+
+func (postgresDialect) AddDays(n string) string {
+	return "((NOW() AT TIME ZONE 'UTC') + make_interval(days => CAST(" + n + " AS INTEGER)))"
+}
+
+func (postgresDialect) Rebind(query string) string {
+	var b strings.Builder
+	b.Grow(len(query) + 8)
+
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteByte('$')
+			b.WriteString(strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func (postgresDialect) Time(t time.Time) any { return t.UTC() }
+
+func (postgresDialect) Returning() bool { return true }
+
+func (postgresDialect) InsertOrIgnore(stmt string) string {
+	return stmt + " ON CONFLICT DO NOTHING"
+}
+
+// FullText uses the weighted search column, which is indexed with GIN. The
+// simple configuration skips stemming and stop words, which suits code.
+func (postgresDialect) FullText(terms []string) (string, []any) {
+	tsquery := strings.Join(terms, ":* & ") + ":*"
+	query := `SELECT id, ts_rank(search, to_tsquery('simple', ?)) AS score FROM snippets
+WHERE search @@ to_tsquery('simple', ?)`
+	return query, []any{tsquery, tsquery}
+}
+
+func (postgresDialect) IsDuplicate(err error, constraint string) bool {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code == "23505" && pgErr.ConstraintName == constraint
+	}
+	return false
+}
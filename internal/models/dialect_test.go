@@ -0,0 +1,24 @@
+package models
+
+import (
+	"testing"
+	"vtorosyan.learning/internal/assert"
+)
+
+func TestRebind(t *testing.T) {
+	query := "SELECT id FROM snippets WHERE id = ? AND expires > " + Postgres.AddDays("?")
+
+	assert.Equal(t, MySQL.Rebind(query), query)
+	assert.Equal(t, SQLite.Rebind(query), query)
+	assert.Equal(t, Postgres.Rebind(query),
+		"SELECT id FROM snippets WHERE id = $1 AND expires > "+
+			"((NOW() AT TIME ZONE 'UTC') + make_interval(days => CAST($2 AS INTEGER)))")
+}
+
+func TestInsertOrIgnore(t *testing.T) {
+	stmt := "INSERT INTO tags (name) VALUES (?)"
+
+	assert.Equal(t, MySQL.InsertOrIgnore(stmt), "INSERT IGNORE INTO tags (name) VALUES (?)")
+	assert.Equal(t, SQLite.InsertOrIgnore(stmt), "INSERT OR IGNORE INTO tags (name) VALUES (?)")
+	assert.Equal(t, Postgres.InsertOrIgnore(stmt), "INSERT INTO tags (name) VALUES (?) ON CONFLICT DO NOTHING")
+}
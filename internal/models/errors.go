@@ -8,4 +8,6 @@ var (
 	ErrInvalidCredentials = errors.New("models: invalid credentials")
 
 	ErrDuplicateEmail = errors.New("models: duplicate email")
+
+	ErrInvalidToken = errors.New("models: invalid or revoked token")
 )
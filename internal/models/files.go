@@ -0,0 +1,91 @@
+package models
+
+import (
+	"slices"
+	"strings"
+)
+
+// MaxFiles limits the files a snippet can hold.
+const MaxFiles = 10
+
+// File is one named file of a snippet. The name is optional, and the
+// language is detected from the content when it is left empty.
+type File struct {
+	Name     string `json:"name,omitempty"`
+	Language string `json:"language,omitempty"`
+	Content  string `json:"content"`
+}
+
+// files returns the files the input holds: Files, or when there are none a
+// single unnamed file made of Content and Language.
+func (input SnippetInput) files() []File {
+	if len(input.Files) == 0 {
+		return []File{{Language: input.Language, Content: input.Content}}
+	}
+	return slices.Clone(input.Files)
+}
+
+// filesContent joins the content of the files, which is what search indexes
+// for a snippet.
+func filesContent(files []File) string {
+	contents := make([]string, len(files))
+	for i, file := range files {
+		contents[i] = file.Content
+	}
+	return strings.Join(contents, "\n")
+}
+
+// setFiles replaces the files of a snippet, numbering them from 1 in order.
+func setFiles(db dbtx, d Dialect, snippetID int, files []File) error {
+	_, err := db.Exec(d.Rebind(`DELETE FROM snippet_files WHERE snippet_id = ?`), snippetID)
+	if err != nil {
+		return err
+	}
+
+	for i, file := range files {
+		stmt := `INSERT INTO snippet_files (snippet_id, position, name, language, content) VALUES (?, ?, ?, ?, ?)`
+		_, err = db.Exec(d.Rebind(stmt), snippetID, i+1, file.Name, file.Language, file.Content)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// loadFiles fills in the files of snippets, in order, with a single query.
+func loadFiles(db dbtx, d Dialect, snippets []Snippet) error {
+	if len(snippets) == 0 {
+		return nil
+	}
+
+	index := make(map[int]int, len(snippets))
+	args := make([]any, len(snippets))
+	for i, snippet := range snippets {
+		index[snippet.ID] = i
+		args[i] = snippet.ID
+	}
+
+	query := `SELECT snippet_id, name, language, content FROM snippet_files
+WHERE snippet_id IN (?` + strings.Repeat(`, ?`, len(snippets)-1) + `) ORDER BY snippet_id, position`
+
+	rows, err := db.Query(d.Rebind(query), args...)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var snippetID int
+		var file File
+		err = rows.Scan(&snippetID, &file.Name, &file.Language, &file.Content)
+		if err != nil {
+			return err
+		}
+		i := index[snippetID]
+		snippets[i].Files = append(snippets[i].Files, file)
+	}
+
+	return rows.Err()
+}
@@ -0,0 +1,40 @@
+package models
+
+import "time"
+
+// Forks returns the live public forks of a snippet, newest first.
+func (s *SnippetModel) Forks(id int) ([]Snippet, error) {
+	query := `SELECT ` + snippetColumns + ` FROM ` + snippetTables + `
+WHERE s.parent_id = ? AND ` + s.live() + listedOnly + ` ORDER BY s.created DESC, s.id DESC`
+
+	return s.query(query, id)
+}
+
+func (m *MemorySnippetModel) Forks(id int) ([]Snippet, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	now := time.Now().UTC()
+	var snippets []Snippet
+	for _, snippet := range m.snippets {
+		if listed(snippet, now) && snippet.ParentID == id {
+			snippets = append(snippets, m.listing(snippet))
+		}
+	}
+
+	sortNewestFirst(snippets)
+
+	return snippets, nil
+}
+
+// orphanForks forgets the parent of the forks of a deleted snippet, like the
+// ON DELETE SET NULL of the SQL schema. It must be called with m.mu held for
+// writing.
+func (m *MemorySnippetModel) orphanForks(id int) {
+	for forkID, snippet := range m.snippets {
+		if snippet.ParentID == id {
+			snippet.ParentID = 0
+			m.snippets[forkID] = snippet
+		}
+	}
+}
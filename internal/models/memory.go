@@ -0,0 +1,626 @@
+package models
+
+import (
+	"errors"
+	"golang.org/x/crypto/bcrypt"
+	"slices"
+	"sync"
+	"time"
+)
+
+// MemorySnippetModel is an in-memory SnippetStore. It is safe for concurrent
+// use and mirrors the SQL models, including hiding expired snippets.
+type MemorySnippetModel struct {
+	mu        sync.RWMutex
+	snippets  map[int]Snippet
+	revisions map[int][]Revision
+	stars     map[int]map[int]time.Time
+	lastID    int
+	users     *MemoryUserModel
+}
+
+// NewMemorySnippetModel returns an empty store which looks up snippet authors
+// in users.
+func NewMemorySnippetModel(users *MemoryUserModel) *MemorySnippetModel {
+	return &MemorySnippetModel{
+		snippets:  make(map[int]Snippet),
+		revisions: make(map[int][]Revision),
+		stars:     make(map[int]map[int]time.Time),
+		users:     users,
+	}
+}
+
+func (m *MemorySnippetModel) Insert(userID int, input SnippetInput) (int, error) {
+	hashedPassword, err := hashSnippetPassword(input.Password)
+	if err != nil {
+		return 0, err
+	}
+
+	var slug string
+	if input.visibility() == VisibilityUnlisted {
+		slug, err = newSlug()
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now().UTC()
+	files := input.files()
+	m.lastID++
+	m.snippets[m.lastID] = Snippet{
+		ID:          m.lastID,
+		Title:       input.Title,
+		Content:     files[0].Content,
+		Language:    files[0].Language,
+		ContentType: input.contentType(),
+		Visibility:  input.visibility(),
+		Slug:        slug,
+		Protected:   hashedPassword != nil,
+		MaxViews:    input.MaxViews,
+		Encrypted:   input.Encrypted,
+		ParentID:    input.ParentID,
+		Created:     now,
+		Updated:     now,
+		Expires:     input.expiry(now),
+		UserID:      userID,
+		Tags:        sortedTags(input.Tags),
+		Files:       files,
+
+		HashedPassword: hashedPassword,
+	}
+	m.addRevision(m.lastID, input.Title, files)
+
+	return m.lastID, nil
+}
+
+func (m *MemorySnippetModel) Get(id int) (Snippet, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	snippet, ok := m.snippets[id]
+	if !ok || !snippet.liveAt(time.Now()) {
+		return Snippet{}, ErrNoRecord
+	}
+
+	return m.joined(snippet), nil
+}
+
+func (m *MemorySnippetModel) GetBySlug(slug string) (Snippet, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	now := time.Now().UTC()
+	for _, snippet := range m.snippets {
+		if slug != "" && snippet.Slug == slug && snippet.liveAt(now) {
+			return m.joined(snippet), nil
+		}
+	}
+
+	return Snippet{}, ErrNoRecord
+}
+
+func (m *MemorySnippetModel) View(id int) (Snippet, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	snippet, ok := m.snippets[id]
+	if !ok || !snippet.liveAt(time.Now()) || snippet.RemainingViews() == 0 {
+		return Snippet{}, ErrNoRecord
+	}
+
+	snippet.Views++
+	if snippet.RemainingViews() == 0 {
+		m.remove(id)
+	} else {
+		m.snippets[id] = snippet
+	}
+
+	return m.joined(snippet), nil
+}
+
+func (m *MemorySnippetModel) Page(order SnippetOrder, after string, before string, limit int) (SnippetPage, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	backwards := before != "" && after == ""
+	var c cursor
+	if after != "" || before != "" {
+		raw := after
+		if backwards {
+			raw = before
+		}
+
+		var err error
+		c, err = decodeCursor(raw)
+		if err != nil {
+			return SnippetPage{}, err
+		}
+	}
+
+	now := time.Now().UTC()
+	var snippets []Snippet
+	for _, snippet := range m.snippets {
+		if !listed(snippet, now) || (order == OrderExpiring && snippet.Expires == nil) {
+			continue
+		}
+		if after != "" && order.position(snippet, c) <= 0 {
+			continue
+		}
+		if backwards && order.position(snippet, c) >= 0 {
+			continue
+		}
+		snippets = append(snippets, m.listing(snippet))
+	}
+
+	slices.SortFunc(snippets, order.less)
+	if backwards {
+		slices.Reverse(snippets)
+	}
+	if len(snippets) > limit+1 {
+		snippets = snippets[:limit+1]
+	}
+
+	return newSnippetPage(order, snippets, limit, backwards, after != "" || before != ""), nil
+}
+
+func (m *MemorySnippetModel) ForUser(userID int) ([]Snippet, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var snippets []Snippet
+	for _, snippet := range m.snippets {
+		if userID != 0 && snippet.UserID == userID {
+			snippets = append(snippets, m.listing(snippet))
+		}
+	}
+
+	sortNewestFirst(snippets)
+
+	return snippets, nil
+}
+
+func (m *MemorySnippetModel) Tagged(tag string) ([]Snippet, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	now := time.Now().UTC()
+	var snippets []Snippet
+	for _, snippet := range m.snippets {
+		if listed(snippet, now) && slices.Contains(snippet.Tags, tag) {
+			snippets = append(snippets, m.listing(snippet))
+		}
+	}
+
+	sortNewestFirst(snippets)
+
+	return snippets, nil
+}
+
+func (m *MemorySnippetModel) Update(id int, userID int, input SnippetInput) error {
+	slug, err := newSlug()
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	snippet, ok := m.snippets[id]
+	if !ok || userID == 0 || snippet.UserID != userID {
+		return ErrNoRecord
+	}
+
+	files := input.files()
+	if input.Title != snippet.Title || !slices.Equal(files, snippet.Files) {
+		m.addRevision(id, input.Title, files)
+	}
+
+	snippet.Title = input.Title
+	snippet.Content = files[0].Content
+	snippet.Language = files[0].Language
+	snippet.Files = files
+	snippet.ContentType = input.contentType()
+	snippet.Visibility = input.visibility()
+	if snippet.Visibility == VisibilityUnlisted && snippet.Slug == "" {
+		snippet.Slug = slug
+	}
+	snippet.Updated = time.Now().UTC()
+	snippet.Tags = sortedTags(input.Tags)
+	if input.changesExpiry() {
+		snippet.Expires = input.expiry(time.Now().UTC())
+	}
+	m.snippets[id] = snippet
+
+	return nil
+}
+
+func (m *MemorySnippetModel) Delete(id int, userID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	snippet, ok := m.snippets[id]
+	if !ok || userID == 0 || snippet.UserID != userID {
+		return ErrNoRecord
+	}
+
+	m.remove(id)
+
+	return nil
+}
+
+func (m *MemorySnippetModel) PurgeExpired(before time.Time, limit int) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var expired []Snippet
+	for _, snippet := range m.snippets {
+		if snippet.Expires != nil && !snippet.Expires.After(before) {
+			expired = append(expired, snippet)
+		}
+	}
+
+	slices.SortFunc(expired, func(a, b Snippet) int {
+		if c := a.Expires.Compare(*b.Expires); c != 0 {
+			return c
+		}
+		return a.ID - b.ID
+	})
+	if len(expired) > limit {
+		expired = expired[:limit]
+	}
+
+	for _, snippet := range expired {
+		m.remove(snippet.ID)
+	}
+
+	return len(expired), nil
+}
+
+func (m *MemorySnippetModel) Revisions(snippetID int) ([]Revision, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	revisions := slices.Clone(m.revisions[snippetID])
+	slices.Reverse(revisions)
+
+	return revisions, nil
+}
+
+// owner returns the id of the user owning a snippet, 0 for anonymous or
+// missing snippets.
+func (m *MemorySnippetModel) owner(id int) int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.snippets[id].UserID
+}
+
+// remove deletes a snippet together with what hangs off it, like the foreign
+// keys of the SQL schema. It must be called with m.mu held for writing.
+func (m *MemorySnippetModel) remove(id int) {
+	delete(m.snippets, id)
+	delete(m.revisions, id)
+	delete(m.stars, id)
+	m.orphanForks(id)
+}
+
+// addRevision must be called with m.mu held for writing.
+func (m *MemorySnippetModel) addRevision(snippetID int, title string, files []File) {
+	m.revisions[snippetID] = append(m.revisions[snippetID], Revision{
+		SnippetID: snippetID,
+		Number:    len(m.revisions[snippetID]) + 1,
+		Title:     title,
+		Content:   files[0].Content,
+		Files:     slices.Clone(files),
+		Created:   time.Now().UTC(),
+	})
+}
+
+// listing returns a snippet as the listings of SnippetModel do, with its
+// author's name and stars but without its files.
+func (m *MemorySnippetModel) listing(snippet Snippet) Snippet {
+	snippet.Files = nil
+	return m.joined(snippet)
+}
+
+// joined fills in the author's name and the number of stars, like the joins
+// in SnippetModel.
+func (m *MemorySnippetModel) joined(snippet Snippet) Snippet {
+	snippet.Stars = len(m.stars[snippet.ID])
+	if m.users != nil {
+		snippet.AuthorName = m.users.name(snippet.UserID)
+	}
+	return snippet
+}
+
+// listed reports whether a snippet belongs in the public listings, like the
+// listedOnly condition in SnippetModel.
+func listed(snippet Snippet, now time.Time) bool {
+	return snippet.liveAt(now) && snippet.Visibility == VisibilityPublic && snippet.MaxViews == 0
+}
+
+func sortNewestFirst(snippets []Snippet) {
+	slices.SortFunc(snippets, func(a, b Snippet) int {
+		if c := b.Created.Compare(a.Created); c != 0 {
+			return c
+		}
+		return b.ID - a.ID
+	})
+}
+
+func (m *MemorySnippetModel) Latest() ([]Snippet, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	now := time.Now().UTC()
+	var snippets []Snippet
+	for _, snippet := range m.snippets {
+		if listed(snippet, now) {
+			snippets = append(snippets, m.listing(snippet))
+		}
+	}
+
+	sortNewestFirst(snippets)
+
+	if len(snippets) > 10 {
+		snippets = snippets[:10]
+	}
+
+	return snippets, nil
+}
+
+// MemoryUserModel is an in-memory UserStore. Passwords are hashed with bcrypt
+// exactly like UserModel, and emails are unique.
+type MemoryUserModel struct {
+	mu     sync.RWMutex
+	users  map[int]Users
+	lastID int
+}
+
+func NewMemoryUserModel() *MemoryUserModel {
+	return &MemoryUserModel{users: make(map[int]Users)}
+}
+
+func (m *MemoryUserModel) Insert(name, email, password string) error {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), 12)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, user := range m.users {
+		if user.Email == email {
+			return ErrDuplicateEmail
+		}
+	}
+
+	m.lastID++
+	m.users[m.lastID] = Users{
+		ID:             m.lastID,
+		Name:           name,
+		Email:          email,
+		HashedPassword: hashedPassword,
+		Created:        time.Now().UTC(),
+	}
+
+	return nil
+}
+
+func (m *MemoryUserModel) Authenticate(email, password string) (int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, user := range m.users {
+		if user.Email != email {
+			continue
+		}
+
+		err := bcrypt.CompareHashAndPassword(user.HashedPassword, []byte(password))
+		if err != nil {
+			if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+				return -1, ErrInvalidCredentials
+			}
+			return -1, err
+		}
+		return user.ID, nil
+	}
+
+	return -1, ErrInvalidCredentials
+}
+
+func (m *MemoryUserModel) name(id int) string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.users[id].Name
+}
+
+func (m *MemoryUserModel) Exists(id int) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	_, ok := m.users[id]
+	return ok, nil
+}
+
+// MemoryTokenModel is an in-memory TokenStore, keyed by token hash like the
+// tokens table.
+type MemoryTokenModel struct {
+	mu     sync.RWMutex
+	tokens map[string]Token
+	lastID int
+}
+
+func NewMemoryTokenModel() *MemoryTokenModel {
+	return &MemoryTokenModel{tokens: make(map[string]Token)}
+}
+
+func (m *MemoryTokenModel) New(userID int, name string) (string, error) {
+	plaintext, hash, err := generateToken()
+	if err != nil {
+		return "", err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.lastID++
+	m.tokens[hash] = Token{
+		ID:      m.lastID,
+		UserID:  userID,
+		Name:    name,
+		Created: time.Now().UTC(),
+	}
+
+	return plaintext, nil
+}
+
+func (m *MemoryTokenModel) ForUser(userID int) ([]Token, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var tokens []Token
+	for _, token := range m.tokens {
+		if token.UserID == userID {
+			tokens = append(tokens, token)
+		}
+	}
+
+	slices.SortFunc(tokens, func(a, b Token) int { return b.ID - a.ID })
+
+	return tokens, nil
+}
+
+func (m *MemoryTokenModel) Revoke(userID, id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for hash, token := range m.tokens {
+		if token.ID == id && token.UserID == userID {
+			delete(m.tokens, hash)
+			return nil
+		}
+	}
+
+	return ErrNoRecord
+}
+
+func (m *MemoryTokenModel) Authenticate(plaintext string) (int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	token, ok := m.tokens[hashToken(plaintext)]
+	if !ok {
+		return 0, ErrInvalidToken
+	}
+
+	return token.UserID, nil
+}
+
+// MemoryCommentModel is an in-memory CommentStore. It looks up the owners of
+// snippets in snippets and the names of authors in users.
+type MemoryCommentModel struct {
+	mu       sync.RWMutex
+	comments map[int]Comment
+	lastID   int
+	snippets *MemorySnippetModel
+	users    *MemoryUserModel
+}
+
+func NewMemoryCommentModel(snippets *MemorySnippetModel, users *MemoryUserModel) *MemoryCommentModel {
+	return &MemoryCommentModel{
+		comments: make(map[int]Comment),
+		snippets: snippets,
+		users:    users,
+	}
+}
+
+func (m *MemoryCommentModel) Insert(snippetID, userID, parentID, file, line int, body string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.lastID++
+	m.comments[m.lastID] = Comment{
+		ID:        m.lastID,
+		SnippetID: snippetID,
+		UserID:    userID,
+		ParentID:  parentID,
+		File:      file,
+		Line:      line,
+		Body:      body,
+		Created:   time.Now().UTC(),
+	}
+
+	return m.lastID, nil
+}
+
+func (m *MemoryCommentModel) Get(id int) (Comment, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	comment, ok := m.comments[id]
+	if !ok {
+		return Comment{}, ErrNoRecord
+	}
+
+	return m.withAuthor(comment), nil
+}
+
+func (m *MemoryCommentModel) ForSnippet(snippetID int) ([]Comment, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var comments []Comment
+	for _, comment := range m.comments {
+		if comment.SnippetID == snippetID {
+			comments = append(comments, m.withAuthor(comment))
+		}
+	}
+
+	slices.SortFunc(comments, func(a, b Comment) int {
+		if c := a.Created.Compare(b.Created); c != 0 {
+			return c
+		}
+		return a.ID - b.ID
+	})
+
+	return comments, nil
+}
+
+func (m *MemoryCommentModel) Delete(id, userID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	comment, ok := m.comments[id]
+	if !ok || userID == 0 || (comment.UserID != userID && m.snippets.owner(comment.SnippetID) != userID) {
+		return ErrNoRecord
+	}
+
+	m.remove(id)
+
+	return nil
+}
+
+// remove deletes a comment and its replies, as the foreign key on parent_id
+// does for CommentModel.
+func (m *MemoryCommentModel) remove(id int) {
+	delete(m.comments, id)
+	for replyID, reply := range m.comments {
+		if reply.ParentID == id {
+			m.remove(replyID)
+		}
+	}
+}
+
+// withAuthor fills in the author's name, like the join in CommentModel.
+func (m *MemoryCommentModel) withAuthor(comment Comment) Comment {
+	if m.users != nil {
+		comment.AuthorName = m.users.name(comment.UserID)
+	}
+	return comment
+}
@@ -0,0 +1,60 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"vtorosyan.learning/internal/assert"
+)
+
+func TestMemorySnippetModel(t *testing.T) {
+	m := NewMemorySnippetModel(NewMemoryUserModel())
+
+	live, err := m.Insert(0, SnippetInput{Title: "Live", Content: "still here", Expires: 7})
+	assert.NilError(t, err)
+	expired, err := m.Insert(0, SnippetInput{Title: "Expired", Content: "already gone", Expires: 0})
+	assert.NilError(t, err)
+
+	snippet, err := m.Get(live)
+	assert.NilError(t, err)
+	assert.Equal(t, snippet.Title, "Live")
+
+	_, err = m.Get(expired)
+	assert.Equal(t, errors.Is(err, ErrNoRecord), true)
+
+	for i := 0; i < 12; i++ {
+		_, err = m.Insert(0, SnippetInput{Title: "Filler", Content: "filler", Expires: 1})
+		assert.NilError(t, err)
+	}
+
+	latest, err := m.Latest()
+	assert.NilError(t, err)
+	assert.Equal(t, len(latest), 10)
+	for _, s := range latest {
+		assert.Equal(t, s.ID != expired, true)
+	}
+}
+
+func TestMemoryUserModel(t *testing.T) {
+	m := NewMemoryUserModel()
+
+	err := m.Insert("Alice", "alice@example.com", "pa$$word")
+	assert.NilError(t, err)
+
+	err = m.Insert("Alice Again", "alice@example.com", "pa$$word")
+	assert.Equal(t, errors.Is(err, ErrDuplicateEmail), true)
+
+	id, err := m.Authenticate("alice@example.com", "pa$$word")
+	assert.NilError(t, err)
+	assert.Equal(t, id, 1)
+
+	_, err = m.Authenticate("alice@example.com", "wrong")
+	assert.Equal(t, errors.Is(err, ErrInvalidCredentials), true)
+
+	exists, err := m.Exists(id)
+	assert.NilError(t, err)
+	assert.Equal(t, exists, true)
+
+	exists, err = m.Exists(42)
+	assert.NilError(t, err)
+	assert.Equal(t, exists, false)
+}
@@ -0,0 +1,255 @@
+package models
+
+import (
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed migrations
+var migrationFiles embed.FS
+
+var migrationDirs = map[Dialect]string{
+	MySQL:    "migrations/mysql",
+	SQLite:   "migrations/sqlite",
+	Postgres: "migrations/postgres",
+}
+
+var ErrNoMigration = errors.New("models: no migration to roll back")
+
+// Migration is one versioned schema change, read from a pair of
+// NNNN_name.up.sql and NNNN_name.down.sql files.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus is a migration together with when it was applied. Applied
+// is nil for pending migrations.
+type MigrationStatus struct {
+	Migration
+	Applied *time.Time
+}
+
+// Migrator brings a database schema to the shape the models expect, keeping
+// track of applied versions in the schema_migrations table.
+type Migrator struct {
+	DB      *sql.DB
+	Dialect Dialect
+}
+
+// Migrations returns the embedded migrations for the dialect, ordered by
+// version.
+func (m *Migrator) Migrations() ([]Migration, error) {
+	dir, ok := migrationDirs[m.Dialect]
+	if !ok {
+		return nil, fmt.Errorf("models: no migrations for driver %s", m.Dialect.DriverName())
+	}
+
+	entries, err := fs.ReadDir(migrationFiles, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, entry := range entries {
+		name, direction, ok := strings.Cut(strings.TrimSuffix(entry.Name(), ".sql"), ".")
+		if !ok || (direction != "up" && direction != "down") {
+			return nil, fmt.Errorf("models: unexpected migration file %s", entry.Name())
+		}
+
+		prefix, name, _ := strings.Cut(name, "_")
+		version, err := strconv.Atoi(prefix)
+		if err != nil {
+			return nil, fmt.Errorf("models: bad migration version in %s: %w", entry.Name(), err)
+		}
+
+		body, err := fs.ReadFile(migrationFiles, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: name}
+			byVersion[version] = migration
+		}
+		if direction == "up" {
+			migration.Up = string(body)
+		} else {
+			migration.Down = string(body)
+		}
+	}
+
+	var migrations []Migration
+	for _, migration := range byVersion {
+		migrations = append(migrations, *migration)
+	}
+	slices.SortFunc(migrations, func(a, b Migration) int { return a.Version - b.Version })
+
+	return migrations, nil
+}
+
+// Status lists every migration and whether it has been applied.
+func (m *Migrator) Status() ([]MigrationStatus, error) {
+	migrations, err := m.Migrations()
+	if err != nil {
+		return nil, err
+	}
+
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	var statuses []MigrationStatus
+	for _, migration := range migrations {
+		status := MigrationStatus{Migration: migration}
+		if at, ok := applied[migration.Version]; ok {
+			status.Applied = &at
+		}
+		statuses = append(statuses, status)
+	}
+
+	return statuses, nil
+}
+
+// Up applies all pending migrations in order and returns the ones it applied.
+func (m *Migrator) Up() ([]Migration, error) {
+	statuses, err := m.Status()
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for _, status := range statuses {
+		if status.Applied != nil {
+			continue
+		}
+
+		err = m.apply(status.Migration.Up,
+			`INSERT INTO schema_migrations (version, name, applied) VALUES (?, ?, `+m.Dialect.Now()+`)`,
+			status.Version, status.Name)
+		if err != nil {
+			return done, fmt.Errorf("models: migration %04d_%s: %w", status.Version, status.Name, err)
+		}
+		done = append(done, status.Migration)
+	}
+
+	return done, nil
+}
+
+// Down rolls back the most recently applied migration.
+func (m *Migrator) Down() (Migration, error) {
+	statuses, err := m.Status()
+	if err != nil {
+		return Migration{}, err
+	}
+
+	for i := len(statuses) - 1; i >= 0; i-- {
+		status := statuses[i]
+		if status.Applied == nil {
+			continue
+		}
+
+		err = m.apply(status.Migration.Down, `DELETE FROM schema_migrations WHERE version = ?`, status.Version)
+		if err != nil {
+			return Migration{}, fmt.Errorf("models: migration %04d_%s: %w", status.Version, status.Name, err)
+		}
+		return status.Migration, nil
+	}
+
+	return Migration{}, ErrNoMigration
+}
+
+// apply runs a migration script and records it in schema_migrations within a
+// single transaction. MySQL commits implicitly around DDL statements, so there
+// a failing script can leave the schema partially migrated.
+func (m *Migrator) apply(script string, record string, args ...any) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, stmt := range m.statements(script) {
+		_, err = tx.Exec(stmt)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Exec(m.Dialect.Rebind(record), args...)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// statements splits a script for drivers that execute a single statement per
+// call. The MySQL driver only accepts several when multiStatements is set in
+// the DSN, so its scripts are split on the semicolons ending each line.
+func (m *Migrator) statements(script string) []string {
+	if m.Dialect != MySQL {
+		return []string{script}
+	}
+
+	var stmts []string
+	var current strings.Builder
+	for _, line := range strings.SplitAfter(script, "\n") {
+		current.WriteString(line)
+		if strings.HasSuffix(strings.TrimSpace(line), ";") {
+			stmts = append(stmts, current.String())
+			current.Reset()
+		}
+	}
+	if strings.TrimSpace(current.String()) != "" {
+		stmts = append(stmts, current.String())
+	}
+
+	return stmts
+}
+
+func (m *Migrator) applied() (map[int]time.Time, error) {
+	_, err := m.DB.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+	version INTEGER NOT NULL PRIMARY KEY,
+	name VARCHAR(255) NOT NULL,
+	applied TIMESTAMP NOT NULL
+)`)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := m.DB.Query(`SELECT version, applied FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[int]time.Time{}
+	for rows.Next() {
+		var version int
+		var at time.Time
+		err = rows.Scan(&version, &at)
+		if err != nil {
+			return nil, err
+		}
+		applied[version] = at
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return applied, nil
+}
@@ -0,0 +1,56 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"vtorosyan.learning/internal/assert"
+)
+
+func TestMigrator(t *testing.T) {
+	db, err := sql.Open(SQLite.DriverName(), SQLiteDSN(filepath.Join(t.TempDir(), "test.db")))
+	assert.NilError(t, err)
+	defer db.Close()
+
+	m := Migrator{DB: db, Dialect: SQLite}
+
+	migrations, err := m.Migrations()
+	assert.NilError(t, err)
+	for i, migration := range migrations {
+		assert.Equal(t, migration.Version, i+1)
+		assert.Equal(t, migration.Up != "" && migration.Down != "", true)
+	}
+
+	for _, dialect := range []Dialect{MySQL, Postgres} {
+		other, err := (&Migrator{Dialect: dialect}).Migrations()
+		assert.NilError(t, err)
+		assert.Equal(t, len(other), len(migrations))
+	}
+
+	applied, err := m.Up()
+	assert.NilError(t, err)
+	assert.Equal(t, len(applied), len(migrations))
+
+	applied, err = m.Up()
+	assert.NilError(t, err)
+	assert.Equal(t, len(applied), 0)
+
+	statuses, err := m.Status()
+	assert.NilError(t, err)
+	for _, status := range statuses {
+		assert.Equal(t, status.Applied != nil, true)
+	}
+
+	for range migrations {
+		_, err = m.Down()
+		assert.NilError(t, err)
+	}
+
+	_, err = m.Down()
+	assert.Equal(t, errors.Is(err, ErrNoMigration), true)
+
+	applied, err = m.Up()
+	assert.NilError(t, err)
+	assert.Equal(t, len(applied), len(migrations))
+}
@@ -0,0 +1,5 @@
+ALTER TABLE snippets DROP FOREIGN KEY fk_snippets_user;
+
+DROP INDEX idx_snippets_user ON snippets;
+
+ALTER TABLE snippets DROP COLUMN user_id;
@@ -0,0 +1,5 @@
+ALTER TABLE snippets ADD COLUMN user_id INTEGER NULL;
+
+CREATE INDEX idx_snippets_user ON snippets(user_id, created);
+
+ALTER TABLE snippets ADD CONSTRAINT fk_snippets_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE SET NULL;
@@ -0,0 +1 @@
+CREATE INDEX idx_snippets_expires ON snippets(expires, id);
@@ -0,0 +1 @@
+CREATE FULLTEXT INDEX idx_snippets_search ON snippets(title, content);
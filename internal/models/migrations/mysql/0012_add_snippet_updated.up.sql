@@ -0,0 +1,5 @@
+ALTER TABLE snippets ADD COLUMN updated DATETIME NULL;
+
+UPDATE snippets SET updated = created;
+
+ALTER TABLE snippets MODIFY updated DATETIME NOT NULL;
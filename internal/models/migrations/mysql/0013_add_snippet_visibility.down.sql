@@ -0,0 +1,5 @@
+DROP INDEX idx_snippets_slug ON snippets;
+
+ALTER TABLE snippets DROP COLUMN slug;
+
+ALTER TABLE snippets DROP COLUMN visibility;
@@ -0,0 +1,5 @@
+ALTER TABLE snippets ADD COLUMN visibility VARCHAR(16) NOT NULL DEFAULT 'public';
+
+ALTER TABLE snippets ADD COLUMN slug VARCHAR(32) NULL;
+
+CREATE UNIQUE INDEX idx_snippets_slug ON snippets(slug);
@@ -0,0 +1,5 @@
+ALTER TABLE snippets DROP FOREIGN KEY fk_snippets_parent;
+
+DROP INDEX idx_snippets_parent ON snippets;
+
+ALTER TABLE snippets DROP COLUMN parent_id;
@@ -0,0 +1,5 @@
+ALTER TABLE snippets ADD COLUMN parent_id INTEGER NULL;
+
+CREATE INDEX idx_snippets_parent ON snippets(parent_id, created);
+
+ALTER TABLE snippets ADD CONSTRAINT fk_snippets_parent FOREIGN KEY (parent_id) REFERENCES snippets(id) ON DELETE SET NULL;
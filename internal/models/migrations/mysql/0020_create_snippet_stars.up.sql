@@ -0,0 +1,11 @@
+CREATE TABLE snippet_stars (
+    snippet_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    created DATETIME NOT NULL,
+    PRIMARY KEY (snippet_id, user_id),
+    CONSTRAINT fk_snippet_stars_snippet FOREIGN KEY (snippet_id) REFERENCES snippets(id) ON DELETE CASCADE,
+    CONSTRAINT fk_snippet_stars_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_snippet_stars_user ON snippet_stars(user_id, created);
+CREATE INDEX idx_snippet_stars_created ON snippet_stars(created);
@@ -0,0 +1,4 @@
+DROP INDEX idx_snippets_search ON snippets;
+CREATE FULLTEXT INDEX idx_snippets_search ON snippets(title, content);
+
+ALTER TABLE snippets DROP COLUMN files_content;
@@ -0,0 +1,11 @@
+ALTER TABLE snippets ADD COLUMN files_content MEDIUMTEXT NULL;
+
+SET SESSION group_concat_max_len = 16777215;
+
+UPDATE snippets SET files_content = COALESCE((SELECT GROUP_CONCAT(f.content ORDER BY f.position SEPARATOR '\n')
+    FROM snippet_files f WHERE f.snippet_id = snippets.id), content);
+
+ALTER TABLE snippets MODIFY files_content MEDIUMTEXT NOT NULL;
+
+DROP INDEX idx_snippets_search ON snippets;
+CREATE FULLTEXT INDEX idx_snippets_search ON snippets(title, files_content);
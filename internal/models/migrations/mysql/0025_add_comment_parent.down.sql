@@ -0,0 +1,5 @@
+ALTER TABLE comments DROP FOREIGN KEY fk_comments_parent;
+
+DROP INDEX idx_comments_parent ON comments;
+
+ALTER TABLE comments DROP COLUMN parent_id;
@@ -0,0 +1,5 @@
+ALTER TABLE comments ADD COLUMN parent_id INTEGER NULL;
+
+CREATE INDEX idx_comments_parent ON comments(parent_id);
+
+ALTER TABLE comments ADD CONSTRAINT fk_comments_parent FOREIGN KEY (parent_id) REFERENCES comments(id) ON DELETE CASCADE;
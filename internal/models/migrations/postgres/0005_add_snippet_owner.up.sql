@@ -0,0 +1,3 @@
+ALTER TABLE snippets ADD COLUMN user_id INTEGER REFERENCES users(id) ON DELETE SET NULL;
+
+CREATE INDEX idx_snippets_user ON snippets(user_id, created);
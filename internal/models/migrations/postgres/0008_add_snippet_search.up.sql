@@ -0,0 +1,4 @@
+ALTER TABLE snippets ADD COLUMN search tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector('simple', title), 'A') || setweight(to_tsvector('simple', content), 'B')
+) STORED;
+CREATE INDEX idx_snippets_search ON snippets USING GIN (search);
@@ -0,0 +1 @@
+ALTER TABLE snippets ALTER COLUMN expires DROP NOT NULL;
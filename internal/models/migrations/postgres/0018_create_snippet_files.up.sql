@@ -0,0 +1,11 @@
+CREATE TABLE snippet_files (
+    snippet_id INTEGER NOT NULL REFERENCES snippets(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    name VARCHAR(100) NOT NULL DEFAULT '',
+    language VARCHAR(32) NOT NULL DEFAULT '',
+    content TEXT NOT NULL,
+    PRIMARY KEY (snippet_id, position)
+);
+
+INSERT INTO snippet_files (snippet_id, position, language, content)
+SELECT id, 1, language, content FROM snippets;
@@ -0,0 +1,10 @@
+CREATE TABLE comments (
+    id SERIAL PRIMARY KEY,
+    snippet_id INTEGER NOT NULL REFERENCES snippets(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    line INTEGER NOT NULL DEFAULT 0,
+    body TEXT NOT NULL,
+    created TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_comments_snippet ON comments(snippet_id, created);
@@ -0,0 +1 @@
+DROP TABLE snippet_revision_files;
@@ -0,0 +1,19 @@
+CREATE TABLE snippet_revision_files (
+    snippet_id INTEGER NOT NULL,
+    revision INTEGER NOT NULL,
+    position INTEGER NOT NULL,
+    name VARCHAR(100) NOT NULL DEFAULT '',
+    language VARCHAR(32) NOT NULL DEFAULT '',
+    content TEXT NOT NULL,
+    PRIMARY KEY (snippet_id, revision, position),
+    FOREIGN KEY (snippet_id, revision) REFERENCES snippet_revisions(snippet_id, revision) ON DELETE CASCADE
+);
+
+INSERT INTO snippet_revision_files (snippet_id, revision, position, name, language, content)
+SELECT r.snippet_id, r.revision, 1, '', '', r.content FROM snippet_revisions r
+WHERE r.revision < (SELECT MAX(m.revision) FROM snippet_revisions m WHERE m.snippet_id = r.snippet_id);
+
+INSERT INTO snippet_revision_files (snippet_id, revision, position, name, language, content)
+SELECT f.snippet_id, r.revision, f.position, f.name, f.language, f.content FROM snippet_files f
+JOIN snippet_revisions r ON r.snippet_id = f.snippet_id
+WHERE r.revision = (SELECT MAX(m.revision) FROM snippet_revisions m WHERE m.snippet_id = f.snippet_id);
@@ -0,0 +1,14 @@
+ALTER TABLE snippets ADD COLUMN files_content TEXT;
+
+UPDATE snippets SET files_content = COALESCE((SELECT string_agg(f.content, E'\n' ORDER BY f.position)
+    FROM snippet_files f WHERE f.snippet_id = snippets.id), content);
+
+ALTER TABLE snippets ALTER COLUMN files_content SET NOT NULL;
+
+DROP INDEX idx_snippets_search;
+ALTER TABLE snippets DROP COLUMN search;
+
+ALTER TABLE snippets ADD COLUMN search tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector('simple', title), 'A') || setweight(to_tsvector('simple', files_content), 'B')
+) STORED;
+CREATE INDEX idx_snippets_search ON snippets USING GIN (search);
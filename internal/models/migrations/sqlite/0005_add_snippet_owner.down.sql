@@ -0,0 +1,3 @@
+DROP INDEX idx_snippets_user;
+
+ALTER TABLE snippets DROP COLUMN user_id;
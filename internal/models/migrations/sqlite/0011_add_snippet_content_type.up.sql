@@ -0,0 +1 @@
+ALTER TABLE snippets ADD COLUMN content_type TEXT NOT NULL DEFAULT 'code';
@@ -0,0 +1,5 @@
+-- SQLite cannot add a NOT NULL column without a default, but every insert
+-- sets updated.
+ALTER TABLE snippets ADD COLUMN updated DATETIME;
+
+UPDATE snippets SET updated = created;
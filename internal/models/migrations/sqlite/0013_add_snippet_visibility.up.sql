@@ -0,0 +1,5 @@
+ALTER TABLE snippets ADD COLUMN visibility TEXT NOT NULL DEFAULT 'public';
+
+ALTER TABLE snippets ADD COLUMN slug TEXT;
+
+CREATE UNIQUE INDEX idx_snippets_slug ON snippets(slug);
@@ -0,0 +1,58 @@
+CREATE TABLE snippets_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    title TEXT NOT NULL,
+    content TEXT NOT NULL,
+    language TEXT NOT NULL DEFAULT '',
+    content_type TEXT NOT NULL DEFAULT 'code',
+    visibility TEXT NOT NULL DEFAULT 'public',
+    slug TEXT,
+    hashed_password TEXT,
+    max_views INTEGER NULL,
+    views INTEGER NOT NULL DEFAULT 0,
+    encrypted INTEGER NOT NULL DEFAULT 0,
+    created DATETIME NOT NULL,
+    updated DATETIME,
+    expires DATETIME NOT NULL,
+    user_id INTEGER REFERENCES users(id) ON DELETE SET NULL
+);
+
+INSERT INTO snippets_new (id, title, content, language, content_type, visibility, slug, hashed_password, max_views, views, encrypted, created, updated, expires, user_id)
+SELECT id, title, content, language, content_type, visibility, slug, hashed_password, max_views, views, encrypted, created, updated, COALESCE(expires, '9999-12-31 23:59:59.000'), user_id FROM snippets;
+
+DELETE FROM sqlite_sequence WHERE name = 'snippets_new';
+INSERT INTO sqlite_sequence (name, seq) SELECT 'snippets_new', seq FROM sqlite_sequence WHERE name = 'snippets';
+
+CREATE TEMP TABLE snippet_revisions_backup AS SELECT * FROM snippet_revisions;
+CREATE TEMP TABLE snippet_tags_backup AS SELECT * FROM snippet_tags;
+
+DROP TRIGGER snippets_fts_insert;
+DROP TRIGGER snippets_fts_delete;
+DROP TRIGGER snippets_fts_update;
+DROP TABLE snippets;
+
+ALTER TABLE snippets_new RENAME TO snippets;
+
+INSERT INTO snippet_revisions SELECT * FROM snippet_revisions_backup;
+INSERT INTO snippet_tags SELECT * FROM snippet_tags_backup;
+DROP TABLE snippet_revisions_backup;
+DROP TABLE snippet_tags_backup;
+
+CREATE INDEX idx_snippets_created ON snippets(created);
+CREATE INDEX idx_snippets_user ON snippets(user_id, created);
+CREATE INDEX idx_snippets_expires ON snippets(expires, id);
+CREATE UNIQUE INDEX idx_snippets_slug ON snippets(slug);
+
+CREATE TRIGGER snippets_fts_insert AFTER INSERT ON snippets BEGIN
+    INSERT INTO snippets_fts(rowid, title, content) VALUES (new.id, new.title, new.content);
+END;
+
+CREATE TRIGGER snippets_fts_delete AFTER DELETE ON snippets BEGIN
+    INSERT INTO snippets_fts(snippets_fts, rowid, title, content) VALUES ('delete', old.id, old.title, old.content);
+END;
+
+CREATE TRIGGER snippets_fts_update AFTER UPDATE OF title, content ON snippets BEGIN
+    INSERT INTO snippets_fts(snippets_fts, rowid, title, content) VALUES ('delete', old.id, old.title, old.content);
+    INSERT INTO snippets_fts(rowid, title, content) VALUES (new.id, new.title, new.content);
+END;
+
+INSERT INTO snippets_fts(snippets_fts) VALUES ('rebuild');
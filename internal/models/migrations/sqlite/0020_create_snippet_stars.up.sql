@@ -0,0 +1,9 @@
+CREATE TABLE snippet_stars (
+    snippet_id INTEGER NOT NULL REFERENCES snippets(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created DATETIME NOT NULL,
+    PRIMARY KEY (snippet_id, user_id)
+);
+
+CREATE INDEX idx_snippet_stars_user ON snippet_stars(user_id, created);
+CREATE INDEX idx_snippet_stars_created ON snippet_stars(created);
@@ -0,0 +1,28 @@
+-- files_content holds the content of every file of a snippet, one after the
+-- other, so that the full text index covers them all.
+ALTER TABLE snippets ADD COLUMN files_content TEXT NOT NULL DEFAULT '';
+
+UPDATE snippets SET files_content = COALESCE((SELECT group_concat(f.content, char(10)) FROM
+    (SELECT content FROM snippet_files WHERE snippet_id = snippets.id ORDER BY position) f), content);
+
+DROP TRIGGER snippets_fts_insert;
+DROP TRIGGER snippets_fts_delete;
+DROP TRIGGER snippets_fts_update;
+DROP TABLE snippets_fts;
+
+CREATE VIRTUAL TABLE snippets_fts USING fts5(title, files_content, content='snippets', content_rowid='id');
+
+INSERT INTO snippets_fts(snippets_fts) VALUES ('rebuild');
+
+CREATE TRIGGER snippets_fts_insert AFTER INSERT ON snippets BEGIN
+    INSERT INTO snippets_fts(rowid, title, files_content) VALUES (new.id, new.title, new.files_content);
+END;
+
+CREATE TRIGGER snippets_fts_delete AFTER DELETE ON snippets BEGIN
+    INSERT INTO snippets_fts(snippets_fts, rowid, title, files_content) VALUES ('delete', old.id, old.title, old.files_content);
+END;
+
+CREATE TRIGGER snippets_fts_update AFTER UPDATE OF title, files_content ON snippets BEGIN
+    INSERT INTO snippets_fts(snippets_fts, rowid, title, files_content) VALUES ('delete', old.id, old.title, old.files_content);
+    INSERT INTO snippets_fts(rowid, title, files_content) VALUES (new.id, new.title, new.files_content);
+END;
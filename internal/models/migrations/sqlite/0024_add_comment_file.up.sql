@@ -0,0 +1,3 @@
+ALTER TABLE comments ADD COLUMN file INTEGER NOT NULL DEFAULT 0;
+
+UPDATE comments SET file = 1 WHERE line > 0;
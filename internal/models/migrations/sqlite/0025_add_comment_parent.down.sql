@@ -0,0 +1,3 @@
+DROP INDEX idx_comments_parent;
+
+ALTER TABLE comments DROP COLUMN parent_id;
@@ -0,0 +1,112 @@
+package models
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SnippetOrder is a sort order for paginated snippet listings.
+type SnippetOrder string
+
+const (
+	OrderNewest   SnippetOrder = "newest"
+	OrderExpiring SnippetOrder = "expiring"
+)
+
+var ErrInvalidCursor = errors.New("models: invalid page cursor")
+
+// SnippetPage is one page of a listing. Next and Prev are opaque cursors for
+// the neighbouring pages and are empty at either end of the listing.
+type SnippetPage struct {
+	Snippets []Snippet
+	Next     string
+	Prev     string
+}
+
+// cursor marks a position in a listing by the sort key and id of a snippet,
+// so pages are found with an index seek instead of an ever growing OFFSET.
+type cursor struct {
+	key time.Time
+	id  int
+}
+
+// sortKey returns the column used by the order and the snippet's value in it.
+// Snippets that never expire are not listed in OrderExpiring.
+func (o SnippetOrder) sortKey(snippet Snippet) (string, time.Time) {
+	if o == OrderExpiring {
+		if snippet.Expires == nil {
+			return "s.expires", time.Time{}
+		}
+		return "s.expires", *snippet.Expires
+	}
+	return "s.created", snippet.Created
+}
+
+// descending reports whether moving forward through the order goes from
+// larger to smaller keys.
+func (o SnippetOrder) descending() bool {
+	return o != OrderExpiring
+}
+
+func encodeCursor(o SnippetOrder, snippet Snippet) string {
+	_, key := o.sortKey(snippet)
+	raw := fmt.Sprintf("%d.%d", key.UnixNano(), snippet.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeCursor(s string) (cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return cursor{}, ErrInvalidCursor
+	}
+
+	nanos, id, ok := strings.Cut(string(raw), ".")
+	if !ok {
+		return cursor{}, ErrInvalidCursor
+	}
+
+	n, err := strconv.ParseInt(nanos, 10, 64)
+	if err != nil {
+		return cursor{}, ErrInvalidCursor
+	}
+	c := cursor{key: time.Unix(0, n).UTC()}
+
+	c.id, err = strconv.Atoi(id)
+	if err != nil {
+		return cursor{}, ErrInvalidCursor
+	}
+
+	return c, nil
+}
+
+// position compares snippet with c going forward through the order: it is
+// negative when the snippet comes before the cursor and positive after it.
+func (o SnippetOrder) position(snippet Snippet, c cursor) int {
+	_, key := o.sortKey(snippet)
+	cmp := key.Compare(c.key)
+	if cmp == 0 {
+		cmp = snippet.ID - c.id
+	}
+	if o.descending() {
+		return -cmp
+	}
+	return cmp
+}
+
+// less orders snippets going forward through the order.
+func (o SnippetOrder) less(a, b Snippet) int {
+	_, keyA := o.sortKey(a)
+	_, keyB := o.sortKey(b)
+	cmp := keyA.Compare(keyB)
+	if cmp == 0 {
+		cmp = a.ID - b.ID
+	}
+	if o.descending() {
+		return -cmp
+	}
+	return cmp
+}
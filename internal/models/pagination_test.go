@@ -0,0 +1,78 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"vtorosyan.learning/internal/assert"
+)
+
+// walkPages pages forward through a listing and back again, returning the ids
+// seen going forward.
+func walkPages(t *testing.T, store SnippetStore, order SnippetOrder, limit int) []int {
+	t.Helper()
+
+	var ids []int
+	var pages []SnippetPage
+
+	page, err := store.Page(order, "", "", limit)
+	assert.NilError(t, err)
+	assert.Equal(t, page.Prev, "")
+	for {
+		pages = append(pages, page)
+		for _, snippet := range page.Snippets {
+			ids = append(ids, snippet.ID)
+		}
+		if page.Next == "" {
+			break
+		}
+		page, err = store.Page(order, page.Next, "", limit)
+		assert.NilError(t, err)
+	}
+
+	for i := len(pages) - 1; i > 0; i-- {
+		prev, err := store.Page(order, "", pages[i].Prev, limit)
+		assert.NilError(t, err)
+		assert.Equal(t, len(prev.Snippets), len(pages[i-1].Snippets))
+		assert.Equal(t, prev.Snippets[0].ID, pages[i-1].Snippets[0].ID)
+		assert.Equal(t, prev.Next, pages[i-1].Next)
+		assert.Equal(t, prev.Prev == "", i == 1)
+	}
+
+	return ids
+}
+
+func testPagination(t *testing.T, store SnippetStore) {
+	for i := 1; i <= 25; i++ {
+		_, err := store.Insert(0, SnippetInput{Title: "Snippet", Content: "content", Expires: 1 + i%3})
+		assert.NilError(t, err)
+	}
+	_, err := store.Insert(0, SnippetInput{Title: "Expired", Content: "content", Expires: 0})
+	assert.NilError(t, err)
+
+	newest := walkPages(t, store, OrderNewest, 10)
+	assert.Equal(t, len(newest), 25)
+	for i, id := range newest {
+		assert.Equal(t, id, 25-i)
+	}
+
+	expiring := walkPages(t, store, OrderExpiring, 10)
+	assert.Equal(t, len(expiring), 25)
+	seen := map[int]bool{}
+	for _, id := range expiring {
+		seen[id] = true
+	}
+	assert.Equal(t, len(seen), 25)
+	// Expiry days cycle through 2, 3, 1, so id 3 is the first one to expire.
+	assert.Equal(t, expiring[0], 3)
+
+	_, err = store.Page(OrderNewest, "not-a-cursor", "", 10)
+	assert.Equal(t, errors.Is(err, ErrInvalidCursor), true)
+}
+
+func TestSnippetModelPage(t *testing.T) {
+	testPagination(t, &SnippetModel{DB: newTestDB(t), Dialect: SQLite})
+}
+
+func TestMemorySnippetModelPage(t *testing.T) {
+	testPagination(t, NewMemorySnippetModel(NewMemoryUserModel()))
+}
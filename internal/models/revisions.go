@@ -0,0 +1,116 @@
+package models
+
+import "time"
+
+// Revision is one saved version of a snippet, with all of its files. Content
+// is that of the first file. Revisions are numbered from 1 and never change
+// once written, so together they form the snippet's history.
+type Revision struct {
+	SnippetID int
+	Number    int
+	Title     string
+	Content   string
+	Files     []File
+	Created   time.Time
+}
+
+// insertRevision appends the given title and files as the next revision of a
+// snippet. It is meant to run inside the transaction that changes the
+// snippet; the unique (snippet_id, revision) constraint rejects a concurrent
+// writer that picked the same number.
+func insertRevision(db dbtx, d Dialect, snippetID int, title string, files []File) error {
+	var number int
+	query := `SELECT COALESCE(MAX(revision), 0) + 1 FROM snippet_revisions WHERE snippet_id = ?`
+	err := db.QueryRow(d.Rebind(query), snippetID).Scan(&number)
+	if err != nil {
+		return err
+	}
+
+	stmt := `INSERT INTO snippet_revisions (snippet_id, revision, title, content, created)
+VALUES (?, ?, ?, ?, ` + d.Now() + `)`
+
+	_, err = db.Exec(d.Rebind(stmt), snippetID, number, title, files[0].Content)
+	if err != nil {
+		return err
+	}
+
+	for i, file := range files {
+		stmt = `INSERT INTO snippet_revision_files (snippet_id, revision, position, name, language, content)
+VALUES (?, ?, ?, ?, ?, ?)`
+		_, err = db.Exec(d.Rebind(stmt), snippetID, number, i+1, file.Name, file.Language, file.Content)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Revisions returns the history of a snippet, newest revision first.
+func (s *SnippetModel) Revisions(snippetID int) ([]Revision, error) {
+	query := `SELECT snippet_id, revision, title, content, created FROM snippet_revisions
+WHERE snippet_id = ? ORDER BY revision DESC`
+
+	rows, err := s.DB.Query(s.Dialect.Rebind(query), snippetID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var revisions []Revision
+
+	for rows.Next() {
+		var revision Revision
+		err = rows.Scan(&revision.SnippetID, &revision.Number, &revision.Title, &revision.Content, &revision.Created)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, revision)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	err = s.loadRevisionFiles(snippetID, revisions)
+	if err != nil {
+		return nil, err
+	}
+
+	return revisions, nil
+}
+
+// loadRevisionFiles fills in the files of the revisions of a snippet, in
+// order, with a single query.
+func (s *SnippetModel) loadRevisionFiles(snippetID int, revisions []Revision) error {
+	index := make(map[int]int, len(revisions))
+	for i, revision := range revisions {
+		index[revision.Number] = i
+	}
+
+	query := `SELECT revision, name, language, content FROM snippet_revision_files
+WHERE snippet_id = ? ORDER BY revision, position`
+
+	rows, err := s.DB.Query(s.Dialect.Rebind(query), snippetID)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var number int
+		var file File
+		err = rows.Scan(&number, &file.Name, &file.Language, &file.Content)
+		if err != nil {
+			return err
+		}
+		i, ok := index[number]
+		if ok {
+			revisions[i].Files = append(revisions[i].Files, file)
+		}
+	}
+
+	return rows.Err()
+}
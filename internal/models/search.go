@@ -0,0 +1,277 @@
+package models
+
+import (
+	"database/sql"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// maxSearchTerms caps how many words of a query are searched for, which keeps
+// the generated full text queries small.
+const maxSearchTerms = 8
+
+// excerptBefore and excerptLength, in characters, size the part of the
+// content shown around the first match.
+const (
+	excerptBefore = 60
+	excerptLength = 240
+)
+
+// SearchResult is a live snippet matching a search, with its title and an
+// excerpt of the content of its files split into fragments so that the
+// matches can be highlighted.
+type SearchResult struct {
+	Snippet
+	Score        float64
+	TitleMatches []Fragment
+	Excerpt      []Fragment
+}
+
+// Fragment is a piece of text which either matches a search term or not.
+type Fragment struct {
+	Text  string
+	Match bool
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// SearchTerms splits a query into the distinct lowercase words it is made of,
+// dropping any punctuation, which every store treats as separators.
+func SearchTerms(query string) []string {
+	var terms []string
+	for _, word := range strings.FieldsFunc(strings.ToLower(query), func(r rune) bool { return !isWordRune(r) }) {
+		if slices.Contains(terms, word) {
+			continue
+		}
+		terms = append(terms, word)
+		if len(terms) == maxSearchTerms {
+			break
+		}
+	}
+	return terms
+}
+
+func matchesTerm(word string, terms []string) bool {
+	word = strings.ToLower(word)
+	for _, term := range terms {
+		if strings.HasPrefix(word, term) {
+			return true
+		}
+	}
+	return false
+}
+
+// highlight splits text into fragments, marking the words which start with one
+// of the terms, the way the full text queries match them.
+func highlight(text string, terms []string) []Fragment {
+	var fragments []Fragment
+	runes := []rune(text)
+
+	plain := 0
+	for i := 0; i < len(runes); {
+		if !isWordRune(runes[i]) {
+			i++
+			continue
+		}
+
+		j := i
+		for j < len(runes) && isWordRune(runes[j]) {
+			j++
+		}
+		if matchesTerm(string(runes[i:j]), terms) {
+			if plain < i {
+				fragments = append(fragments, Fragment{Text: string(runes[plain:i])})
+			}
+			fragments = append(fragments, Fragment{Text: string(runes[i:j]), Match: true})
+			plain = j
+		}
+		i = j
+	}
+
+	if plain < len(runes) {
+		fragments = append(fragments, Fragment{Text: string(runes[plain:])})
+	}
+
+	return fragments
+}
+
+// excerpt returns the highlighted part of content around its first match,
+// with whitespace collapsed so that it reads as a single line.
+func excerpt(content string, terms []string) []Fragment {
+	runes := []rune(strings.Join(strings.Fields(content), " "))
+
+	first := 0
+	for _, fragment := range highlight(string(runes), terms) {
+		if fragment.Match {
+			break
+		}
+		first += len([]rune(fragment.Text))
+	}
+	if first == len(runes) {
+		first = 0
+	}
+
+	start := max(first-excerptBefore, 0)
+	end := min(start+excerptLength, len(runes))
+
+	// Avoid cutting into words at either end.
+	for start > 0 && start < first && runes[start-1] != ' ' {
+		start++
+	}
+	for end < len(runes) && end > first && runes[end] != ' ' {
+		end--
+	}
+
+	fragments := highlight(string(runes[start:end]), terms)
+	if start > 0 {
+		fragments = slices.Insert(fragments, 0, Fragment{Text: "… "})
+	}
+	if end < len(runes) {
+		fragments = append(fragments, Fragment{Text: " …"})
+	}
+
+	return fragments
+}
+
+func newSearchResult(snippet Snippet, content string, score float64, terms []string) SearchResult {
+	return SearchResult{
+		Snippet:      snippet,
+		Score:        score,
+		TitleMatches: highlight(snippet.Title, terms),
+		Excerpt:      excerpt(content, terms),
+	}
+}
+
+// scoreScanner reads the content of every file and the score following the
+// snippet columns of a row.
+type scoreScanner struct {
+	rows    *sql.Rows
+	content *string
+	score   *float64
+}
+
+func (s scoreScanner) Scan(dest ...any) error {
+	return s.rows.Scan(append(dest, s.content, s.score)...)
+}
+
+// Search returns up to limit live public snippets whose title or files
+// contain every word of the query, most relevant first. The ranking comes
+// from the database's own full text index, so it differs slightly between
+// dialects. Password protected snippets are left out, as matching them would
+// give their content away, and so are encrypted ones, whose content is noise.
+func (s *SnippetModel) Search(query string, limit int) ([]SearchResult, error) {
+	terms := SearchTerms(query)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+
+	fullText, args := s.Dialect.FullText(terms)
+	stmt := `SELECT ` + snippetColumns + `, s.files_content, m.score FROM snippets s
+JOIN (` + fullText + `) m ON m.id = s.id
+LEFT JOIN users u ON u.id = s.user_id
+WHERE ` + s.live() + listedOnly + ` AND s.hashed_password IS NULL AND s.encrypted = ?
+ORDER BY m.score DESC, s.id DESC LIMIT ?`
+	args = append(args, false, limit)
+
+	rows, err := s.DB.Query(s.Dialect.Rebind(stmt), args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var snippets []Snippet
+	var contents []string
+	var scores []float64
+
+	for rows.Next() {
+		var content string
+		var score float64
+		snippet, err := scanSnippet(scoreScanner{rows: rows, content: &content, score: &score})
+		if err != nil {
+			return nil, err
+		}
+		snippets = append(snippets, snippet)
+		contents = append(contents, content)
+		scores = append(scores, score)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	err = loadTags(s.DB, s.Dialect, snippets)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]SearchResult, len(snippets))
+	for i, snippet := range snippets {
+		results[i] = newSearchResult(snippet, contents[i], scores[i], terms)
+	}
+
+	return results, nil
+}
+
+// Search ranks snippets by how often the terms occur in them, counting a
+// match in the title ten times, close to the weighting of the SQLite index.
+func (m *MemorySnippetModel) Search(query string, limit int) ([]SearchResult, error) {
+	terms := SearchTerms(query)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	now := time.Now().UTC()
+	var results []SearchResult
+	for _, snippet := range m.snippets {
+		if !listed(snippet, now) || snippet.Protected || snippet.Encrypted {
+			continue
+		}
+
+		content := filesContent(snippet.Files)
+		score := 0
+		for _, term := range terms {
+			hits := 10*countMatches(snippet.Title, term) + countMatches(content, term)
+			if hits == 0 {
+				score = 0
+				break
+			}
+			score += hits
+		}
+		if score > 0 {
+			results = append(results, newSearchResult(m.listing(snippet), content, float64(score), terms))
+		}
+	}
+
+	slices.SortFunc(results, func(a, b SearchResult) int {
+		if a.Score != b.Score {
+			if a.Score > b.Score {
+				return -1
+			}
+			return 1
+		}
+		return b.ID - a.ID
+	})
+	if len(results) > limit {
+		results = results[:limit]
+	}
+
+	return results, nil
+}
+
+func countMatches(text string, term string) int {
+	n := 0
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool { return !isWordRune(r) }) {
+		if strings.HasPrefix(word, term) {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,106 @@
+package models
+
+import (
+	"strings"
+	"testing"
+	"vtorosyan.learning/internal/assert"
+)
+
+func testSearch(t *testing.T, store SnippetStore) {
+	inContent, err := store.Insert(0, SnippetInput{Title: "Snail", Content: "An old silent pond...\nA frog jumps into the pond,\nsplash! Silence again.", Expires: 7})
+	assert.NilError(t, err)
+	inTitle, err := store.Insert(0, SnippetInput{Title: "Pond at night", Content: "Moonlight on water", Expires: 7})
+	assert.NilError(t, err)
+	_, err = store.Insert(0, SnippetInput{Title: "Expired pond", Content: "pond pond pond", Expires: 0})
+	assert.NilError(t, err)
+
+	results, err := store.Search("pond", 10)
+	assert.NilError(t, err)
+	assert.Equal(t, len(results), 2)
+	assert.Equal(t, results[0].ID, inTitle)
+	assert.Equal(t, results[1].ID, inContent)
+	assert.Equal(t, results[0].TitleMatches[0], Fragment{Text: "Pond", Match: true})
+
+	// Every word has to match, as a prefix, and punctuation is ignored.
+	results, err = store.Search("SILEN* frog!", 10)
+	assert.NilError(t, err)
+	assert.Equal(t, len(results), 1)
+	assert.Equal(t, results[0].ID, inContent)
+
+	results, err = store.Search("pond toad", 10)
+	assert.NilError(t, err)
+	assert.Equal(t, len(results), 0)
+
+	results, err = store.Search(`"" -- ()`, 10)
+	assert.NilError(t, err)
+	assert.Equal(t, len(results), 0)
+
+	results, err = store.Search("pond", 1)
+	assert.NilError(t, err)
+	assert.Equal(t, len(results), 1)
+
+	// Every file of a snippet is searched, and the excerpt comes from the one
+	// matching.
+	files := []File{{Name: "spring", Content: "Plum blossoms"}, {Name: "winter", Content: "First snow on the pond"}}
+	inFile, err := store.Insert(0, SnippetInput{Title: "Seasons", Files: files, Expires: 7})
+	assert.NilError(t, err)
+	results, err = store.Search("snow", 10)
+	assert.NilError(t, err)
+	assert.Equal(t, len(results), 1)
+	assert.Equal(t, results[0].ID, inFile)
+	assert.Equal(t, results[0].Excerpt[1], Fragment{Text: "snow", Match: true})
+}
+
+func TestSnippetModelSearch(t *testing.T) {
+	db := newTestDB(t)
+	m := &SnippetModel{DB: db, Dialect: SQLite}
+	testSearch(t, m)
+
+	// The index follows updates and deletes through triggers.
+	id, err := m.Insert(0, SnippetInput{Title: "Haiku", Content: "cherry blossoms", Expires: 7})
+	assert.NilError(t, err)
+	_, err = db.Exec(`UPDATE snippets SET files_content = 'autumn moon' WHERE id = ?`, id)
+	assert.NilError(t, err)
+
+	results, err := m.Search("cherry", 10)
+	assert.NilError(t, err)
+	assert.Equal(t, len(results), 0)
+	results, err = m.Search("autumn", 10)
+	assert.NilError(t, err)
+	assert.Equal(t, len(results), 1)
+
+	_, err = db.Exec(`DELETE FROM snippets WHERE id = ?`, id)
+	assert.NilError(t, err)
+	results, err = m.Search("autumn", 10)
+	assert.NilError(t, err)
+	assert.Equal(t, len(results), 0)
+}
+
+func TestMemorySnippetModelSearch(t *testing.T) {
+	testSearch(t, NewMemorySnippetModel(NewMemoryUserModel()))
+}
+
+func TestExcerpt(t *testing.T) {
+	content := strings.Repeat("lorem ipsum ", 30) + "needle\n\nin   the haystack " + strings.Repeat("dolor sit ", 30)
+
+	fragments := excerpt(content, []string{"needle", "hay"})
+
+	var text strings.Builder
+	var matches []string
+	for _, fragment := range fragments {
+		text.WriteString(fragment.Text)
+		if fragment.Match {
+			matches = append(matches, fragment.Text)
+		}
+	}
+
+	assert.Equal(t, strings.Join(matches, ","), "needle,haystack")
+	assert.StringContains(t, text.String(), "needle in the haystack")
+	assert.Equal(t, strings.HasPrefix(text.String(), "… lorem") || strings.HasPrefix(text.String(), "… ipsum"), true)
+	assert.Equal(t, strings.HasSuffix(text.String(), "sit …") || strings.HasSuffix(text.String(), "dolor …"), true)
+	assert.Equal(t, len([]rune(text.String())) <= excerptLength+4, true)
+
+	fragments = excerpt("short and sweet", []string{"nothing"})
+	assert.Equal(t, len(fragments), 1)
+	assert.Equal(t, fragments[0].Text, "short and sweet")
+}
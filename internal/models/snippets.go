@@ -3,40 +3,356 @@ package models
 import (
 	"database/sql"
 	"errors"
+	"golang.org/x/crypto/bcrypt"
+	"slices"
+	"strings"
 	"time"
 )
 
+// Snippet is a snippet of one or more files. Content and Language are those
+// of its first file. Files are only loaded for a single snippet, not for
+// listings.
 type Snippet struct {
-	ID      int
-	Title   string
-	Content string
-	Created time.Time
-	Expires time.Time
+	ID          int         `json:"id"`
+	Title       string      `json:"title"`
+	Content     string      `json:"content"`
+	Language    string      `json:"language,omitempty"`
+	ContentType ContentType `json:"content_type"`
+	Visibility  Visibility  `json:"visibility"`
+	Slug        string      `json:"slug,omitempty"`
+	Protected   bool        `json:"protected,omitempty"`
+	Encrypted   bool        `json:"encrypted,omitempty"`
+	MaxViews    int         `json:"max_views,omitempty"`
+	Views       int         `json:"views,omitempty"`
+	Stars       int         `json:"stars"`
+	Created     time.Time   `json:"created"`
+	Updated     time.Time   `json:"updated"`
+	Expires     *time.Time  `json:"expires"`
+	UserID      int         `json:"user_id,omitempty"`
+	ParentID    int         `json:"parent_id,omitempty"`
+	AuthorName  string      `json:"author,omitempty"`
+	Tags        []string    `json:"tags,omitempty"`
+	Files       []File      `json:"files,omitempty"`
+
+	// HashedPassword is the bcrypt hash of the password protecting the
+	// snippet, nil when there is none.
+	HashedPassword []byte `json:"-"`
+}
+
+// Expired reports whether the snippet is past its expiry time. Only the
+// owner's listing ever returns expired snippets. Snippets with a nil Expires
+// never expire.
+func (s Snippet) Expired() bool {
+	return !s.liveAt(time.Now())
+}
+
+// liveAt reports whether the snippet has not expired by the given time.
+func (s Snippet) liveAt(t time.Time) bool {
+	return s.Expires == nil || s.Expires.After(t)
+}
+
+// RemainingViews returns how many more times a snippet with a view limit can
+// be viewed.
+func (s Snippet) RemainingViews() int {
+	return max(s.MaxViews-s.Views, 0)
+}
+
+// MatchesPassword reports whether password unlocks a protected snippet.
+func (s Snippet) MatchesPassword(password string) (bool, error) {
+	err := bcrypt.CompareHashAndPassword(s.HashedPassword, []byte(password))
+	if err != nil {
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// hashSnippetPassword hashes a snippet password like UserModel hashes user
+// passwords. An empty password leaves the snippet unprotected and hashes to
+// nil.
+func hashSnippetPassword(password string) ([]byte, error) {
+	if password == "" {
+		return nil, nil
+	}
+	return bcrypt.GenerateFromPassword([]byte(password), 12)
+}
+
+// ContentType says how a snippet's content is displayed.
+type ContentType string
+
+const (
+	// ContentCode is shown as highlighted source code.
+	ContentCode ContentType = "code"
+	// ContentMarkdown is rendered as a Markdown document.
+	ContentMarkdown ContentType = "markdown"
+)
+
+// SnippetInput holds the fields of a snippet chosen by its author, when
+// creating or updating it. Files replaces its set of files, in order; without
+// any, Content and Language make up a single file. Expires is the number of
+// days the snippet lives for, unless ExpiresAt sets a specific time or
+// NeverExpires is set; Update keeps the current expiry when none of them is.
+// Password and MaxViews, the number of times the snippet can be viewed or 0
+// for no limit, are only set on creation, and Update ignores them. So are
+// Encrypted, which marks Content as ciphertext that only the browser can
+// decrypt, and ParentID, the snippet this one is a fork of.
+type SnippetInput struct {
+	Title        string
+	Content      string
+	Language     string
+	ContentType  ContentType
+	Visibility   Visibility
+	Tags         []string
+	Files        []File
+	Expires      int
+	ExpiresAt    time.Time
+	NeverExpires bool
+	Password     string
+	MaxViews     int
+	Encrypted    bool
+	ParentID     int
+}
+
+// changesExpiry reports whether the input sets an expiry, rather than keeping
+// the current one.
+func (input SnippetInput) changesExpiry() bool {
+	return input.NeverExpires || !input.ExpiresAt.IsZero() || input.Expires > 0
+}
+
+// expiry returns the expiry time the input asks for, counted from now, or nil
+// for a snippet that never expires.
+func (input SnippetInput) expiry(now time.Time) *time.Time {
+	switch {
+	case input.NeverExpires:
+		return nil
+	case !input.ExpiresAt.IsZero():
+		expires := input.ExpiresAt.UTC()
+		return &expires
+	default:
+		expires := now.AddDate(0, 0, input.Expires)
+		return &expires
+	}
+}
+
+// contentType defaults an unset content type to ContentCode.
+func (input SnippetInput) contentType() ContentType {
+	if input.ContentType == "" {
+		return ContentCode
+	}
+	return input.ContentType
+}
+
+// SnippetStore is the storage-agnostic set of snippet operations the web
+// application depends on.
+type SnippetStore interface {
+	Insert(userID int, input SnippetInput) (int, error)
+	Get(id int) (Snippet, error)
+	GetBySlug(slug string) (Snippet, error)
+	View(id int) (Snippet, error)
+	Latest() ([]Snippet, error)
+	Page(order SnippetOrder, after string, before string, limit int) (SnippetPage, error)
+	ForUser(userID int) ([]Snippet, error)
+	Tagged(tag string) ([]Snippet, error)
+	Forks(id int) ([]Snippet, error)
+	Star(id int, userID int) error
+	Unstar(id int, userID int) error
+	HasStarred(id int, userID int) (bool, error)
+	Starred(userID int) ([]Snippet, error)
+	MostStarred(since time.Time, limit int) ([]Snippet, error)
+	Update(id int, userID int, input SnippetInput) error
+	Delete(id int, userID int) error
+	PurgeExpired(before time.Time, limit int) (int, error)
+	Revisions(snippetID int) ([]Revision, error)
+	Search(query string, limit int) ([]SearchResult, error)
 }
 
 type SnippetModel struct {
-	DB *sql.DB
+	DB      *sql.DB
+	Dialect Dialect
+}
+
+// snippetColumns and snippetTables are shared by every snippet query so that
+// scanSnippet can read the rows, including the author's name and the number
+// of stars.
+const (
+	snippetColumns = `s.id, s.title, s.content, s.language, s.content_type, s.visibility, s.slug, s.created, s.updated, s.expires, s.user_id, COALESCE(u.name, ''), s.hashed_password, s.max_views, s.views, s.encrypted, s.parent_id, (SELECT COUNT(*) FROM snippet_stars ss WHERE ss.snippet_id = s.id)`
+	snippetTables  = `snippets s LEFT JOIN users u ON u.id = s.user_id`
+)
+
+// live restricts a query over snippets s to the ones which have not expired.
+func (s *SnippetModel) live() string {
+	return `(s.expires IS NULL OR s.expires > ` + s.Dialect.Now() + `)`
 }
 
-func (s *SnippetModel) Insert(title string, content string, expires int) (int, error) {
-	stmt := `INSERT INTO snippetbox.snippets (title, content, created, expires)
-VALUES(?, ?, UTC_TIMESTAMP(), DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? DAY))`
+// expiry returns the SQL expression for the expiry the input asks for, and
+// its arguments.
+func (s *SnippetModel) expiry(input SnippetInput) (string, []any) {
+	switch {
+	case input.NeverExpires:
+		return `NULL`, nil
+	case !input.ExpiresAt.IsZero():
+		return `?`, []any{s.Dialect.Time(input.ExpiresAt)}
+	default:
+		return s.Dialect.AddDays("?"), []any{input.Expires}
+	}
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanSnippet(row scanner) (Snippet, error) {
+	var snippet Snippet
+	var userID sql.NullInt64
+	var slug, hashedPassword sql.NullString
+	var maxViews sql.NullInt64
+	var expires sql.NullTime
+	var parentID sql.NullInt64
+
+	err := row.Scan(&snippet.ID, &snippet.Title, &snippet.Content, &snippet.Language, &snippet.ContentType,
+		&snippet.Visibility, &slug, &snippet.Created, &snippet.Updated, &expires,
+		&userID, &snippet.AuthorName, &hashedPassword, &maxViews, &snippet.Views,
+		&snippet.Encrypted, &parentID, &snippet.Stars)
+	if err != nil {
+		return Snippet{}, err
+	}
+
+	snippet.UserID = int(userID.Int64)
+	snippet.ParentID = int(parentID.Int64)
+	snippet.Slug = slug.String
+	snippet.MaxViews = int(maxViews.Int64)
+	if expires.Valid {
+		snippet.Expires = &expires.Time
+	}
+	if hashedPassword.Valid {
+		snippet.HashedPassword = []byte(hashedPassword.String)
+		snippet.Protected = true
+	}
+	return snippet, nil
+}
+
+// Insert stores a new snippet owned by userID, together with its tags, files
+// and first revision. A userID of 0 stores an anonymous snippet. Unlisted snippets
+// are given a slug, and a non-empty Password is stored hashed.
+func (s *SnippetModel) Insert(userID int, input SnippetInput) (int, error) {
+	hashedPassword, err := hashSnippetPassword(input.Password)
+	if err != nil {
+		return 0, err
+	}
+
+	var slug sql.NullString
+	if input.visibility() == VisibilityUnlisted {
+		slug.String, err = newSlug()
+		if err != nil {
+			return 0, err
+		}
+		slug.Valid = true
+	}
+
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	expires, expiresArgs := s.expiry(input)
+	stmt := `INSERT INTO snippets (title, content, files_content, language, content_type, visibility, slug, hashed_password, max_views,
+encrypted, parent_id, created, updated, expires, user_id)
+VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ` + s.Dialect.Now() + `, ` + s.Dialect.Now() + `, ` + expires + `, ?)`
 
-	rslt, err := s.DB.Exec(stmt, title, content, expires)
+	owner := sql.NullInt64{Int64: int64(userID), Valid: userID != 0}
+	password := sql.NullString{String: string(hashedPassword), Valid: hashedPassword != nil}
+	maxViews := sql.NullInt64{Int64: int64(input.MaxViews), Valid: input.MaxViews > 0}
+	parent := sql.NullInt64{Int64: int64(input.ParentID), Valid: input.ParentID != 0}
+	files := input.files()
+	args := []any{input.Title, files[0].Content, filesContent(files), files[0].Language, input.contentType(),
+		input.visibility(), slug, password, maxViews, input.Encrypted, parent}
+	args = append(args, expiresArgs...)
+	id, err := insert(tx, s.Dialect, stmt, append(args, owner)...)
 	if err != nil {
 		return 0, err
 	}
-	id, err := rslt.LastInsertId()
-	return int(id), err
+
+	err = setTags(tx, s.Dialect, id, input.Tags)
+	if err != nil {
+		return 0, err
+	}
+
+	err = setFiles(tx, s.Dialect, id, files)
+	if err != nil {
+		return 0, err
+	}
+
+	err = insertRevision(tx, s.Dialect, id, input.Title, files)
+	if err != nil {
+		return 0, err
+	}
+
+	return id, tx.Commit()
 }
 
+// Get returns a live snippet by id, whatever its visibility. Callers decide
+// whether it may be shown with Snippet.VisibleTo. It does not count as a view
+// of a snippet with a view limit; see View.
 func (s *SnippetModel) Get(id int) (Snippet, error) {
-	query := `SELECT id, title, content, created, expires FROM snippetbox.snippets
-WHERE expires > UTC_TIMESTAMP() AND id = ?`
+	return s.get(s.DB, `s.id = ?`, id)
+}
 
-	var snippet Snippet
-	row := s.DB.QueryRow(query, id)
-	err := row.Scan(&snippet.ID, &snippet.Title, &snippet.Content, &snippet.Created, &snippet.Expires)
+// GetBySlug returns a live snippet by its slug, whatever its visibility.
+func (s *SnippetModel) GetBySlug(slug string) (Snippet, error) {
+	return s.get(s.DB, `s.slug = ?`, slug)
+}
+
+// View counts a view of a live snippet with a view limit and returns it, or
+// ErrNoRecord if it has no views left. The check and the count are a single
+// UPDATE, so concurrent viewers can never see the snippet more times than its
+// limit between them. The viewer who uses up the last view also deletes the
+// snippet, in the same transaction.
+func (s *SnippetModel) View(id int) (Snippet, error) {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return Snippet{}, err
+	}
+	defer tx.Rollback()
+
+	stmt := `UPDATE snippets SET views = views + 1
+WHERE id = ? AND (expires IS NULL OR expires > ` + s.Dialect.Now() + `) AND max_views IS NOT NULL AND views < max_views`
+
+	rslt, err := tx.Exec(s.Dialect.Rebind(stmt), id)
+	if err != nil {
+		return Snippet{}, err
+	}
+
+	n, err := rslt.RowsAffected()
+	if err != nil {
+		return Snippet{}, err
+	}
+	if n == 0 {
+		return Snippet{}, ErrNoRecord
+	}
+
+	snippet, err := s.get(tx, `s.id = ?`, id)
+	if err != nil {
+		return Snippet{}, err
+	}
+
+	if snippet.RemainingViews() == 0 {
+		_, err = tx.Exec(s.Dialect.Rebind(`DELETE FROM snippets WHERE id = ?`), id)
+		if err != nil {
+			return Snippet{}, err
+		}
+	}
+
+	return snippet, tx.Commit()
+}
+
+func (s *SnippetModel) get(db dbtx, where string, arg any) (Snippet, error) {
+	query := `SELECT ` + snippetColumns + ` FROM ` + snippetTables + `
+WHERE ` + s.live() + ` AND ` + where
+
+	snippet, err := scanSnippet(db.QueryRow(s.Dialect.Rebind(query), arg))
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return Snippet{}, ErrNoRecord
@@ -45,13 +361,259 @@ WHERE expires > UTC_TIMESTAMP() AND id = ?`
 		}
 	}
 
-	return snippet, nil
+	snippets := []Snippet{snippet}
+	err = loadTags(db, s.Dialect, snippets)
+	if err != nil {
+		return Snippet{}, err
+	}
+
+	err = loadFiles(db, s.Dialect, snippets)
+	if err != nil {
+		return Snippet{}, err
+	}
+
+	return snippets[0], nil
 }
+
 func (s *SnippetModel) Latest() ([]Snippet, error) {
-	query := `SELECT id, title, content, created, expires FROM snippetbox.snippets 
-WHERE expires > UTC_TIMESTAMP() ORDER BY created DESC LIMIT 10`
+	query := `SELECT ` + snippetColumns + ` FROM ` + snippetTables + `
+WHERE ` + s.live() + listedOnly + ` ORDER BY s.created DESC LIMIT 10`
 
-	rows, err := s.DB.Query(query)
+	return s.query(query)
+}
+
+// Page returns up to limit live public snippets in the given order, starting after
+// the after cursor, or ending before the before cursor when that is set
+// instead. With neither it returns the first page. OrderExpiring leaves out
+// the snippets that never expire.
+func (s *SnippetModel) Page(order SnippetOrder, after string, before string, limit int) (SnippetPage, error) {
+	column, _ := order.sortKey(Snippet{})
+
+	forward, backward := "<", ">"
+	direction, reverse := "DESC", "ASC"
+	if !order.descending() {
+		forward, backward = backward, forward
+		direction, reverse = reverse, direction
+	}
+
+	query := `SELECT ` + snippetColumns + ` FROM ` + snippetTables + `
+WHERE ` + s.live() + listedOnly
+	if order == OrderExpiring {
+		query += ` AND s.expires IS NOT NULL`
+	}
+	var args []any
+
+	backwards := before != "" && after == ""
+	if after != "" || before != "" {
+		cmp, raw := forward, after
+		if backwards {
+			cmp, raw = backward, before
+		}
+
+		c, err := decodeCursor(raw)
+		if err != nil {
+			return SnippetPage{}, err
+		}
+
+		query += ` AND (` + column + ` ` + cmp + ` ? OR (` + column + ` = ? AND s.id ` + cmp + ` ?))`
+		args = append(args, s.Dialect.Time(c.key), s.Dialect.Time(c.key), c.id)
+	}
+
+	if backwards {
+		direction = reverse
+	}
+	query += ` ORDER BY ` + column + ` ` + direction + `, s.id ` + direction + ` LIMIT ?`
+	args = append(args, limit+1)
+
+	snippets, err := s.query(query, args...)
+	if err != nil {
+		return SnippetPage{}, err
+	}
+
+	return newSnippetPage(order, snippets, limit, backwards, after != "" || before != ""), nil
+}
+
+// newSnippetPage trims the extra row fetched to detect whether there is a
+// further page and works out the cursors. Rows fetched backwards come in
+// reverse order.
+func newSnippetPage(order SnippetOrder, snippets []Snippet, limit int, backwards bool, hasCursor bool) SnippetPage {
+	more := len(snippets) > limit
+	if more {
+		snippets = snippets[:limit]
+	}
+	if backwards {
+		slices.Reverse(snippets)
+	}
+
+	page := SnippetPage{Snippets: snippets}
+	if len(snippets) == 0 {
+		return page
+	}
+
+	hasNext, hasPrev := more, hasCursor
+	if backwards {
+		hasNext, hasPrev = hasCursor, more
+	}
+	if hasNext {
+		page.Next = encodeCursor(order, snippets[len(snippets)-1])
+	}
+	if hasPrev {
+		page.Prev = encodeCursor(order, snippets[0])
+	}
+
+	return page
+}
+
+// ForUser returns every snippet owned by the user, newest first, including
+// the ones that have already expired.
+func (s *SnippetModel) ForUser(userID int) ([]Snippet, error) {
+	query := `SELECT ` + snippetColumns + ` FROM ` + snippetTables + `
+WHERE s.user_id = ? ORDER BY s.created DESC`
+
+	return s.query(query, userID)
+}
+
+// Update changes the title, files, visibility and tags of a snippet owned by
+// userID, recording a new revision if the title or any of the files differ
+// from the current ones. An input which sets an expiry also resets it,
+// counting any days from now; otherwise the current one is kept. A snippet which becomes
+// unlisted keeps the slug it had before, if any, so old links keep working.
+func (s *SnippetModel) Update(id int, userID int, input SnippetInput) error {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	current := []Snippet{{ID: id}}
+	query := `SELECT title FROM snippets WHERE id = ? AND user_id = ?`
+	err = tx.QueryRow(s.Dialect.Rebind(query), id, userID).Scan(&current[0].Title)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNoRecord
+		}
+		return err
+	}
+
+	err = loadFiles(tx, s.Dialect, current)
+	if err != nil {
+		return err
+	}
+
+	files := input.files()
+	stmt := `UPDATE snippets SET title = ?, content = ?, files_content = ?, language = ?, content_type = ?, visibility = ?, updated = ` +
+		s.Dialect.Now()
+	args := []any{input.Title, files[0].Content, filesContent(files), files[0].Language, input.contentType(), input.visibility()}
+
+	if input.visibility() == VisibilityUnlisted {
+		slug, err := newSlug()
+		if err != nil {
+			return err
+		}
+		stmt += `, slug = COALESCE(slug, ?)`
+		args = append(args, slug)
+	}
+
+	if input.changesExpiry() {
+		expires, expiresArgs := s.expiry(input)
+		stmt += `, expires = ` + expires
+		args = append(args, expiresArgs...)
+	}
+
+	stmt += ` WHERE id = ?`
+	args = append(args, id)
+
+	_, err = tx.Exec(s.Dialect.Rebind(stmt), args...)
+	if err != nil {
+		return err
+	}
+
+	err = setTags(tx, s.Dialect, id, input.Tags)
+	if err != nil {
+		return err
+	}
+
+	err = setFiles(tx, s.Dialect, id, files)
+	if err != nil {
+		return err
+	}
+
+	if input.Title != current[0].Title || !slices.Equal(files, current[0].Files) {
+		err = insertRevision(tx, s.Dialect, id, input.Title, files)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Delete removes a snippet owned by userID.
+func (s *SnippetModel) Delete(id int, userID int) error {
+	stmt := `DELETE FROM snippets WHERE id = ? AND user_id = ?`
+
+	rslt, err := s.DB.Exec(s.Dialect.Rebind(stmt), id, userID)
+	if err != nil {
+		return err
+	}
+
+	n, err := rslt.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+// PurgeExpired deletes up to limit snippets which expired at or before the
+// given time, oldest first, together with their revisions and tags, and
+// returns how many it deleted. Callers purge in batches by calling it until it
+// returns less than limit.
+func (s *SnippetModel) PurgeExpired(before time.Time, limit int) (int, error) {
+	query := `SELECT id FROM snippets WHERE expires <= ? ORDER BY expires, id LIMIT ?`
+
+	rows, err := s.DB.Query(s.Dialect.Rebind(query), s.Dialect.Time(before), limit)
+	if err != nil {
+		return 0, err
+	}
+
+	defer rows.Close()
+
+	args := []any{s.Dialect.Time(before)}
+	for rows.Next() {
+		var id int
+		err = rows.Scan(&id)
+		if err != nil {
+			return 0, err
+		}
+		args = append(args, id)
+	}
+
+	if err = rows.Err(); err != nil {
+		return 0, err
+	}
+	if len(args) == 1 {
+		return 0, nil
+	}
+
+	// Checking the expiry again leaves alone any snippet whose expiry was
+	// pushed back in the meantime.
+	stmt := `DELETE FROM snippets WHERE expires <= ? AND id IN (?` + strings.Repeat(`, ?`, len(args)-2) + `)`
+
+	rslt, err := s.DB.Exec(s.Dialect.Rebind(stmt), args...)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := rslt.RowsAffected()
+	return int(n), err
+}
+
+func (s *SnippetModel) query(query string, args ...any) ([]Snippet, error) {
+	rows, err := s.DB.Query(s.Dialect.Rebind(query), args...)
 	if err != nil {
 		return nil, err
 	}
@@ -61,14 +623,9 @@ WHERE expires > UTC_TIMESTAMP() ORDER BY created DESC LIMIT 10`
 	var snippets []Snippet
 
 	for rows.Next() {
-		var snippet Snippet
-		err = rows.Scan(&snippet.ID, &snippet.Title, &snippet.Content, &snippet.Created, &snippet.Expires)
+		snippet, err := scanSnippet(rows)
 		if err != nil {
-			if errors.Is(err, sql.ErrNoRows) {
-				return nil, ErrNoRecord
-			} else {
-				return nil, err
-			}
+			return nil, err
 		}
 		snippets = append(snippets, snippet)
 	}
@@ -77,5 +634,10 @@ WHERE expires > UTC_TIMESTAMP() ORDER BY created DESC LIMIT 10`
 		return nil, err
 	}
 
+	err = loadTags(s.DB, s.Dialect, snippets)
+	if err != nil {
+		return nil, err
+	}
+
 	return snippets, nil
 }
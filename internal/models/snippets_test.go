@@ -0,0 +1,662 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"vtorosyan.learning/internal/assert"
+)
+
+func TestSnippetModel(t *testing.T) {
+	m := SnippetModel{DB: newTestDB(t), Dialect: SQLite}
+
+	live, err := m.Insert(0, SnippetInput{Title: "Live", Content: "still here", Expires: 7})
+	assert.NilError(t, err)
+	expired, err := m.Insert(0, SnippetInput{Title: "Expired", Content: "already gone", Expires: 0})
+	assert.NilError(t, err)
+
+	snippet, err := m.Get(live)
+	assert.NilError(t, err)
+	assert.Equal(t, snippet.Title, "Live")
+	assert.Equal(t, snippet.Content, "still here")
+	assert.Equal(t, snippet.Expires.Sub(snippet.Created).Round(time.Hour), 7*24*time.Hour)
+
+	_, err = m.Get(expired)
+	assert.Equal(t, errors.Is(err, ErrNoRecord), true)
+
+	latest, err := m.Latest()
+	assert.NilError(t, err)
+	assert.Equal(t, len(latest), 1)
+	assert.Equal(t, latest[0].ID, live)
+}
+
+func TestSnippetModelForUser(t *testing.T) {
+	db := newTestDB(t)
+	users := UserModel{DB: db, Dialect: SQLite}
+	m := SnippetModel{DB: db, Dialect: SQLite}
+
+	assert.NilError(t, users.Insert("Alice", "alice@example.com", "pa$$word"))
+
+	live, err := m.Insert(1, SnippetInput{Title: "Live", Content: "still here", Expires: 7})
+	assert.NilError(t, err)
+	_, err = m.Insert(1, SnippetInput{Title: "Expired", Content: "already gone", Expires: 0})
+	assert.NilError(t, err)
+	_, err = m.Insert(0, SnippetInput{Title: "Anonymous", Content: "nobody's", Expires: 7})
+	assert.NilError(t, err)
+
+	snippet, err := m.Get(live)
+	assert.NilError(t, err)
+	assert.Equal(t, snippet.UserID, 1)
+	assert.Equal(t, snippet.AuthorName, "Alice")
+
+	owned, err := m.ForUser(1)
+	assert.NilError(t, err)
+	assert.Equal(t, len(owned), 2)
+	assert.Equal(t, owned[0].Expired() != owned[1].Expired(), true)
+}
+
+func TestSnippetModelUpdateDelete(t *testing.T) {
+	db := newTestDB(t)
+	users := UserModel{DB: db, Dialect: SQLite}
+	m := SnippetModel{DB: db, Dialect: SQLite}
+
+	assert.NilError(t, users.Insert("Alice", "alice@example.com", "pa$$word"))
+
+	id, err := m.Insert(1, SnippetInput{Title: "Draft", Content: "first version", Expires: 1})
+	assert.NilError(t, err)
+	before, err := m.Get(id)
+	assert.NilError(t, err)
+	assert.Equal(t, before.Updated.Equal(before.Created), true)
+
+	time.Sleep(5 * time.Millisecond)
+	assert.NilError(t, m.Update(id, 1, SnippetInput{Title: "Final", Content: "second version"}))
+	after, err := m.Get(id)
+	assert.NilError(t, err)
+	assert.Equal(t, after.Title, "Final")
+	assert.Equal(t, after.Expires.Equal(*before.Expires), true)
+	assert.Equal(t, after.Updated.After(before.Updated), true)
+
+	assert.NilError(t, m.Update(id, 1, SnippetInput{Title: "Final", Content: "second version", Expires: 365}))
+	after, err = m.Get(id)
+	assert.NilError(t, err)
+	assert.Equal(t, after.Expires.After(before.Expires.AddDate(0, 0, 300)), true)
+
+	err = m.Delete(id, 2)
+	assert.Equal(t, errors.Is(err, ErrNoRecord), true)
+	assert.NilError(t, m.Delete(id, 1))
+
+	_, err = m.Get(id)
+	assert.Equal(t, errors.Is(err, ErrNoRecord), true)
+}
+
+func TestSnippetModelRevisions(t *testing.T) {
+	db := newTestDB(t)
+	users := UserModel{DB: db, Dialect: SQLite}
+	m := SnippetModel{DB: db, Dialect: SQLite}
+
+	assert.NilError(t, users.Insert("Alice", "alice@example.com", "pa$$word"))
+
+	id, err := m.Insert(1, SnippetInput{Title: "Draft", Content: "first version", Expires: 7})
+	assert.NilError(t, err)
+	assert.NilError(t, m.Update(id, 1, SnippetInput{Title: "Draft", Content: "second version"}))
+	assert.NilError(t, m.Update(id, 1, SnippetInput{Title: "Draft", Content: "second version", Expires: 7}))
+	assert.NilError(t, m.Update(id, 1, SnippetInput{Title: "Final", Content: "second version"}))
+
+	revisions, err := m.Revisions(id)
+	assert.NilError(t, err)
+	assert.Equal(t, len(revisions), 3)
+	assert.Equal(t, revisions[0].Number, 3)
+	assert.Equal(t, revisions[0].Title, "Final")
+	assert.Equal(t, revisions[1].Content, "second version")
+	assert.Equal(t, revisions[2].Content, "first version")
+
+	err = m.Update(id, 2, SnippetInput{Title: "Hijacked", Content: ""})
+	assert.Equal(t, errors.Is(err, ErrNoRecord), true)
+
+	assert.NilError(t, m.Delete(id, 1))
+	revisions, err = m.Revisions(id)
+	assert.NilError(t, err)
+	assert.Equal(t, len(revisions), 0)
+}
+
+func testTags(t *testing.T, store SnippetStore) {
+	id, err := store.Insert(0, SnippetInput{Title: "Server", Content: "http.ListenAndServe", Tags: []string{"go", "http"}, Expires: 7})
+	assert.NilError(t, err)
+	_, err = store.Insert(0, SnippetInput{Title: "Client", Content: "http.Get", Tags: []string{"http"}, Expires: 7})
+	assert.NilError(t, err)
+	_, err = store.Insert(0, SnippetInput{Title: "Expired", Content: "http.Head", Tags: []string{"http"}, Expires: 0})
+	assert.NilError(t, err)
+
+	snippet, err := store.Get(id)
+	assert.NilError(t, err)
+	assert.Equal(t, strings.Join(snippet.Tags, ","), "go,http")
+
+	tagged, err := store.Tagged("http")
+	assert.NilError(t, err)
+	assert.Equal(t, len(tagged), 2)
+	assert.Equal(t, tagged[0].Title, "Client")
+	assert.Equal(t, strings.Join(tagged[1].Tags, ","), "go,http")
+
+	_, err = store.Insert(1, SnippetInput{Title: "Mine", Content: "mine", Tags: []string{"zig"}, Expires: 7})
+	assert.NilError(t, err)
+	owned, err := store.ForUser(1)
+	assert.NilError(t, err)
+	assert.NilError(t, store.Update(owned[0].ID, 1, SnippetInput{Title: "Mine", Content: "mine", Tags: []string{"zig", "ansi-c"}}))
+
+	snippet, err = store.Get(owned[0].ID)
+	assert.NilError(t, err)
+	assert.Equal(t, strings.Join(snippet.Tags, ","), "ansi-c,zig")
+
+	tagged, err = store.Tagged("go")
+	assert.NilError(t, err)
+	assert.Equal(t, len(tagged), 1)
+}
+
+func TestSnippetModelTags(t *testing.T) {
+	db := newTestDB(t)
+	users := UserModel{DB: db, Dialect: SQLite}
+	assert.NilError(t, users.Insert("Alice", "alice@example.com", "pa$$word"))
+
+	testTags(t, &SnippetModel{DB: db, Dialect: SQLite})
+}
+
+func TestMemorySnippetModelTags(t *testing.T) {
+	users := NewMemoryUserModel()
+	assert.NilError(t, users.Insert("Alice", "alice@example.com", "pa$$word"))
+
+	testTags(t, NewMemorySnippetModel(users))
+}
+
+func TestParseTags(t *testing.T) {
+	assert.Equal(t, strings.Join(ParseTags(" Go,http  GO,\tcli,,"), "|"), "go|http|cli")
+	assert.Equal(t, len(ParseTags(" , ")), 0)
+}
+
+func testVisibility(t *testing.T, store SnippetStore) {
+	_, err := store.Insert(1, SnippetInput{Title: "Public", Content: "for everyone", Tags: []string{"go"}, Expires: 7})
+	assert.NilError(t, err)
+	unlisted, err := store.Insert(1, SnippetInput{Title: "Unlisted", Content: "for everyone",
+		Visibility: VisibilityUnlisted, Tags: []string{"go"}, Expires: 7})
+	assert.NilError(t, err)
+	private, err := store.Insert(1, SnippetInput{Title: "Private", Content: "for everyone",
+		Visibility: VisibilityPrivate, Tags: []string{"go"}, Expires: 7})
+	assert.NilError(t, err)
+
+	snippet, err := store.Get(unlisted)
+	assert.NilError(t, err)
+	assert.Equal(t, snippet.Visibility, VisibilityUnlisted)
+	assert.Equal(t, snippet.Ref(), snippet.Slug)
+
+	bySlug, err := store.GetBySlug(snippet.Slug)
+	assert.NilError(t, err)
+	assert.Equal(t, bySlug.ID, unlisted)
+	assert.Equal(t, bySlug.VisibleTo(0, true), true)
+	assert.Equal(t, bySlug.VisibleTo(0, false), false)
+	assert.Equal(t, bySlug.VisibleTo(1, false), true)
+
+	_, err = store.GetBySlug("")
+	assert.Equal(t, errors.Is(err, ErrNoRecord), true)
+
+	latest, err := store.Latest()
+	assert.NilError(t, err)
+	assert.Equal(t, len(latest), 1)
+	page, err := store.Page(OrderNewest, "", "", 10)
+	assert.NilError(t, err)
+	assert.Equal(t, len(page.Snippets), 1)
+	tagged, err := store.Tagged("go")
+	assert.NilError(t, err)
+	assert.Equal(t, len(tagged), 1)
+	results, err := store.Search("everyone", 10)
+	assert.NilError(t, err)
+	assert.Equal(t, len(results), 1)
+
+	owned, err := store.ForUser(1)
+	assert.NilError(t, err)
+	assert.Equal(t, len(owned), 3)
+
+	// Making a private snippet unlisted gives it a slug, which then survives
+	// further edits.
+	snippet, err = store.Get(private)
+	assert.NilError(t, err)
+	assert.Equal(t, snippet.Slug, "")
+	assert.Equal(t, snippet.VisibleTo(0, false), false)
+	assert.Equal(t, snippet.VisibleTo(2, true), false)
+
+	assert.NilError(t, store.Update(private, 1, SnippetInput{Title: "Shared", Content: "now", Visibility: VisibilityUnlisted}))
+	snippet, err = store.Get(private)
+	assert.NilError(t, err)
+	slug := snippet.Slug
+	assert.Equal(t, slug != "", true)
+
+	assert.NilError(t, store.Update(private, 1, SnippetInput{Title: "Shared", Content: "again", Visibility: VisibilityUnlisted}))
+	snippet, err = store.Get(private)
+	assert.NilError(t, err)
+	assert.Equal(t, snippet.Slug, slug)
+}
+
+func TestSnippetModelVisibility(t *testing.T) {
+	db := newTestDB(t)
+	users := UserModel{DB: db, Dialect: SQLite}
+	assert.NilError(t, users.Insert("Alice", "alice@example.com", "pa$$word"))
+
+	testVisibility(t, &SnippetModel{DB: db, Dialect: SQLite})
+}
+
+func TestMemorySnippetModelVisibility(t *testing.T) {
+	users := NewMemoryUserModel()
+	assert.NilError(t, users.Insert("Alice", "alice@example.com", "pa$$word"))
+
+	testVisibility(t, NewMemorySnippetModel(users))
+}
+
+func testPassword(t *testing.T, store SnippetStore) {
+	id, err := store.Insert(1, SnippetInput{Title: "Vendor config", Content: "api_key = secret",
+		Password: "open sesame", Expires: 7})
+	assert.NilError(t, err)
+	_, err = store.Insert(1, SnippetInput{Title: "Public config", Content: "api_key = none", Expires: 7})
+	assert.NilError(t, err)
+
+	snippet, err := store.Get(id)
+	assert.NilError(t, err)
+	assert.Equal(t, snippet.Protected, true)
+
+	match, err := snippet.MatchesPassword("open sesame")
+	assert.NilError(t, err)
+	assert.Equal(t, match, true)
+	match, err = snippet.MatchesPassword("open sesame!")
+	assert.NilError(t, err)
+	assert.Equal(t, match, false)
+
+	// Updates leave the password alone.
+	assert.NilError(t, store.Update(id, 1, SnippetInput{Title: "Vendor config", Content: "api_key = rotated"}))
+	snippet, err = store.Get(id)
+	assert.NilError(t, err)
+	assert.Equal(t, snippet.Protected, true)
+
+	results, err := store.Search("api_key", 10)
+	assert.NilError(t, err)
+	assert.Equal(t, len(results), 1)
+	assert.Equal(t, results[0].Title, "Public config")
+}
+
+func TestSnippetModelPassword(t *testing.T) {
+	db := newTestDB(t)
+	users := UserModel{DB: db, Dialect: SQLite}
+	assert.NilError(t, users.Insert("Alice", "alice@example.com", "pa$$word"))
+
+	testPassword(t, &SnippetModel{DB: db, Dialect: SQLite})
+}
+
+func TestMemorySnippetModelPassword(t *testing.T) {
+	users := NewMemoryUserModel()
+	assert.NilError(t, users.Insert("Alice", "alice@example.com", "pa$$word"))
+
+	testPassword(t, NewMemorySnippetModel(users))
+}
+
+func testViews(t *testing.T, store SnippetStore) {
+	limited, err := store.Insert(1, SnippetInput{Title: "Twice", Content: "twice", MaxViews: 2, Expires: 7})
+	assert.NilError(t, err)
+	unlimited, err := store.Insert(1, SnippetInput{Title: "Always", Content: "always", Expires: 7})
+	assert.NilError(t, err)
+
+	// Get does not count as a view, and limited snippets are never listed.
+	snippet, err := store.Get(limited)
+	assert.NilError(t, err)
+	assert.Equal(t, snippet.RemainingViews(), 2)
+	latest, err := store.Latest()
+	assert.NilError(t, err)
+	assert.Equal(t, len(latest), 1)
+
+	snippet, err = store.View(limited)
+	assert.NilError(t, err)
+	assert.Equal(t, snippet.Content, "twice")
+	assert.Equal(t, snippet.RemainingViews(), 1)
+
+	snippet, err = store.View(limited)
+	assert.NilError(t, err)
+	assert.Equal(t, snippet.RemainingViews(), 0)
+
+	_, err = store.View(limited)
+	assert.Equal(t, errors.Is(err, ErrNoRecord), true)
+	_, err = store.Get(limited)
+	assert.Equal(t, errors.Is(err, ErrNoRecord), true)
+
+	_, err = store.View(unlimited)
+	assert.Equal(t, errors.Is(err, ErrNoRecord), true)
+
+	// However many readers race for a burn-once snippet, only one gets it.
+	burn, err := store.Insert(1, SnippetInput{Title: "Burn", Content: "once", MaxViews: 1, Expires: 7})
+	assert.NilError(t, err)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	seen := 0
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := store.View(burn)
+			if err == nil {
+				mu.Lock()
+				seen++
+				mu.Unlock()
+			} else if !errors.Is(err, ErrNoRecord) {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, seen, 1)
+}
+
+func TestSnippetModelViews(t *testing.T) {
+	db := newTestDB(t)
+	users := UserModel{DB: db, Dialect: SQLite}
+	assert.NilError(t, users.Insert("Alice", "alice@example.com", "pa$$word"))
+
+	testViews(t, &SnippetModel{DB: db, Dialect: SQLite})
+}
+
+func TestMemorySnippetModelViews(t *testing.T) {
+	users := NewMemoryUserModel()
+	assert.NilError(t, users.Insert("Alice", "alice@example.com", "pa$$word"))
+
+	testViews(t, NewMemorySnippetModel(users))
+}
+
+func testPurgeExpired(t *testing.T, store SnippetStore) {
+	live, err := store.Insert(0, SnippetInput{Title: "Live", Content: "live", Expires: 7})
+	assert.NilError(t, err)
+	for range 3 {
+		_, err = store.Insert(0, SnippetInput{Title: "Expired", Content: "expired", Expires: 0})
+		assert.NilError(t, err)
+	}
+
+	// Nothing expired over an hour ago.
+	n, err := store.PurgeExpired(time.Now().Add(-time.Hour), 10)
+	assert.NilError(t, err)
+	assert.Equal(t, n, 0)
+
+	n, err = store.PurgeExpired(time.Now().Add(time.Second), 2)
+	assert.NilError(t, err)
+	assert.Equal(t, n, 2)
+	n, err = store.PurgeExpired(time.Now().Add(time.Second), 2)
+	assert.NilError(t, err)
+	assert.Equal(t, n, 1)
+
+	revisions, err := store.Revisions(2)
+	assert.NilError(t, err)
+	assert.Equal(t, len(revisions), 0)
+
+	_, err = store.Get(live)
+	assert.NilError(t, err)
+}
+
+func TestSnippetModelPurgeExpired(t *testing.T) {
+	testPurgeExpired(t, &SnippetModel{DB: newTestDB(t), Dialect: SQLite})
+}
+
+func TestMemorySnippetModelPurgeExpired(t *testing.T) {
+	testPurgeExpired(t, NewMemorySnippetModel(NewMemoryUserModel()))
+}
+
+func testExpiry(t *testing.T, store SnippetStore) {
+	never, err := store.Insert(1, SnippetInput{Title: "Never", Content: "forever", NeverExpires: true})
+	assert.NilError(t, err)
+	at := time.Now().Add(48 * time.Hour).Truncate(time.Second)
+	dated, err := store.Insert(1, SnippetInput{Title: "Dated", Content: "until then", ExpiresAt: at})
+	assert.NilError(t, err)
+	past, err := store.Insert(1, SnippetInput{Title: "Past", Content: "gone", ExpiresAt: time.Now().Add(-time.Hour)})
+	assert.NilError(t, err)
+
+	snippet, err := store.Get(never)
+	assert.NilError(t, err)
+	assert.Equal(t, snippet.Expires == nil, true)
+	assert.Equal(t, snippet.Expired(), false)
+
+	snippet, err = store.Get(dated)
+	assert.NilError(t, err)
+	assert.Equal(t, snippet.Expires.Equal(at), true)
+
+	_, err = store.Get(past)
+	assert.Equal(t, errors.Is(err, ErrNoRecord), true)
+
+	latest, err := store.Latest()
+	assert.NilError(t, err)
+	assert.Equal(t, len(latest), 2)
+
+	page, err := store.Page(OrderExpiring, "", "", 10)
+	assert.NilError(t, err)
+	assert.Equal(t, len(page.Snippets), 1)
+	assert.Equal(t, page.Snippets[0].ID, dated)
+
+	n, err := store.PurgeExpired(time.Now(), 10)
+	assert.NilError(t, err)
+	assert.Equal(t, n, 1)
+
+	// Updates keep a snippet that never expires as it is, unless asked.
+	assert.NilError(t, store.Update(never, 1, SnippetInput{Title: "Never", Content: "still forever"}))
+	snippet, err = store.Get(never)
+	assert.NilError(t, err)
+	assert.Equal(t, snippet.Expires == nil, true)
+
+	assert.NilError(t, store.Update(never, 1, SnippetInput{Title: "Never", Content: "not anymore", Expires: 7}))
+	snippet, err = store.Get(never)
+	assert.NilError(t, err)
+	assert.Equal(t, snippet.Expires != nil, true)
+
+	assert.NilError(t, store.Update(dated, 1, SnippetInput{Title: "Dated", Content: "forever now", NeverExpires: true}))
+	snippet, err = store.Get(dated)
+	assert.NilError(t, err)
+	assert.Equal(t, snippet.Expires == nil, true)
+}
+
+func TestSnippetModelExpiry(t *testing.T) {
+	db := newTestDB(t)
+	users := UserModel{DB: db, Dialect: SQLite}
+	assert.NilError(t, users.Insert("Alice", "alice@example.com", "pa$$word"))
+
+	testExpiry(t, &SnippetModel{DB: db, Dialect: SQLite})
+}
+
+func TestMemorySnippetModelExpiry(t *testing.T) {
+	users := NewMemoryUserModel()
+	assert.NilError(t, users.Insert("Alice", "alice@example.com", "pa$$word"))
+
+	testExpiry(t, NewMemorySnippetModel(users))
+}
+
+func testFiles(t *testing.T, store SnippetStore) {
+	files := []File{
+		{Name: "main.go", Language: "go", Content: "package main"},
+		{Name: "go.mod", Content: "module example"},
+	}
+	id, err := store.Insert(1, SnippetInput{Title: "Module", Files: files, Expires: 7})
+	assert.NilError(t, err)
+
+	snippet, err := store.Get(id)
+	assert.NilError(t, err)
+	assert.Equal(t, len(snippet.Files), 2)
+	assert.Equal(t, snippet.Files[1], files[1])
+	assert.Equal(t, snippet.Content, "package main")
+	assert.Equal(t, snippet.Language, "go")
+
+	// A snippet created from Content alone has it as its only file.
+	single, err := store.Insert(1, SnippetInput{Title: "Single", Content: "just this", Expires: 7})
+	assert.NilError(t, err)
+	snippet, err = store.Get(single)
+	assert.NilError(t, err)
+	assert.Equal(t, len(snippet.Files), 1)
+	assert.Equal(t, snippet.Files[0].Content, "just this")
+
+	files = []File{{Name: "go.mod", Content: "module example"}, {Name: "README", Content: "Read me"}}
+	assert.NilError(t, store.Update(id, 1, SnippetInput{Title: "Module", Files: files}))
+	snippet, err = store.Get(id)
+	assert.NilError(t, err)
+	assert.Equal(t, len(snippet.Files), 2)
+	assert.Equal(t, snippet.Files[0].Name, "go.mod")
+	assert.Equal(t, snippet.Content, "module example")
+
+	revisions, err := store.Revisions(id)
+	assert.NilError(t, err)
+	assert.Equal(t, len(revisions), 2)
+
+	// Changing any file, not just the first, records a revision with the
+	// whole set of files.
+	files = []File{{Name: "go.mod", Content: "module example"}, {Name: "README", Content: "Read me first"}}
+	assert.NilError(t, store.Update(id, 1, SnippetInput{Title: "Module", Files: files}))
+	revisions, err = store.Revisions(id)
+	assert.NilError(t, err)
+	assert.Equal(t, len(revisions), 3)
+	assert.Equal(t, len(revisions[0].Files), 2)
+	assert.Equal(t, revisions[0].Files[1], files[1])
+	assert.Equal(t, revisions[0].Content, "module example")
+	assert.Equal(t, len(revisions[2].Files), 2)
+	assert.Equal(t, revisions[2].Files[0].Name, "main.go")
+
+	// Saving the same files again does not.
+	assert.NilError(t, store.Update(id, 1, SnippetInput{Title: "Module", Files: files}))
+	revisions, err = store.Revisions(id)
+	assert.NilError(t, err)
+	assert.Equal(t, len(revisions), 3)
+
+	// Search covers the updated files, not just the first one.
+	results, err := store.Search("first", 10)
+	assert.NilError(t, err)
+	assert.Equal(t, len(results), 1)
+	assert.Equal(t, results[0].ID, id)
+}
+
+func TestSnippetModelFiles(t *testing.T) {
+	db := newTestDB(t)
+	users := UserModel{DB: db, Dialect: SQLite}
+	assert.NilError(t, users.Insert("Alice", "alice@example.com", "pa$$word"))
+
+	testFiles(t, &SnippetModel{DB: db, Dialect: SQLite})
+}
+
+func TestMemorySnippetModelFiles(t *testing.T) {
+	users := NewMemoryUserModel()
+	assert.NilError(t, users.Insert("Alice", "alice@example.com", "pa$$word"))
+
+	testFiles(t, NewMemorySnippetModel(users))
+}
+
+func testForks(t *testing.T, store SnippetStore) {
+	parent, err := store.Insert(1, SnippetInput{Title: "Original", Content: "v1", Expires: 7})
+	assert.NilError(t, err)
+	fork, err := store.Insert(1, SnippetInput{Title: "Fork", Content: "v2", Expires: 7, ParentID: parent})
+	assert.NilError(t, err)
+	_, err = store.Insert(1, SnippetInput{Title: "Private fork", Content: "v3", Expires: 7, ParentID: parent,
+		Visibility: VisibilityPrivate})
+	assert.NilError(t, err)
+
+	snippet, err := store.Get(fork)
+	assert.NilError(t, err)
+	assert.Equal(t, snippet.ParentID, parent)
+
+	forks, err := store.Forks(parent)
+	assert.NilError(t, err)
+	assert.Equal(t, len(forks), 1)
+	assert.Equal(t, forks[0].ID, fork)
+
+	assert.NilError(t, store.Delete(parent, 1))
+	snippet, err = store.Get(fork)
+	assert.NilError(t, err)
+	assert.Equal(t, snippet.ParentID, 0)
+}
+
+func TestSnippetModelForks(t *testing.T) {
+	db := newTestDB(t)
+	users := UserModel{DB: db, Dialect: SQLite}
+	assert.NilError(t, users.Insert("Alice", "alice@example.com", "pa$$word"))
+
+	testForks(t, &SnippetModel{DB: db, Dialect: SQLite})
+}
+
+func TestMemorySnippetModelForks(t *testing.T) {
+	users := NewMemoryUserModel()
+	assert.NilError(t, users.Insert("Alice", "alice@example.com", "pa$$word"))
+
+	testForks(t, NewMemorySnippetModel(users))
+}
+
+func testStars(t *testing.T, store SnippetStore) {
+	popular, err := store.Insert(1, SnippetInput{Title: "Popular", Content: "a", Expires: 7})
+	assert.NilError(t, err)
+	liked, err := store.Insert(1, SnippetInput{Title: "Liked", Content: "b", Expires: 7})
+	assert.NilError(t, err)
+	private, err := store.Insert(1, SnippetInput{Title: "Private", Content: "c", Expires: 7, Visibility: VisibilityPrivate})
+	assert.NilError(t, err)
+
+	assert.NilError(t, store.Star(popular, 1))
+	assert.NilError(t, store.Star(popular, 2))
+	assert.NilError(t, store.Star(popular, 2))
+	assert.NilError(t, store.Star(liked, 2))
+	assert.NilError(t, store.Star(private, 1))
+	assert.NilError(t, store.Star(private, 2))
+	assert.NilError(t, store.Star(99, 2))
+
+	snippet, err := store.Get(popular)
+	assert.NilError(t, err)
+	assert.Equal(t, snippet.Stars, 2)
+
+	starred, err := store.HasStarred(liked, 2)
+	assert.NilError(t, err)
+	assert.Equal(t, starred, true)
+	starred, err = store.HasStarred(liked, 1)
+	assert.NilError(t, err)
+	assert.Equal(t, starred, false)
+
+	snippets, err := store.Starred(2)
+	assert.NilError(t, err)
+	assert.Equal(t, len(snippets), 2)
+	assert.Equal(t, snippets[0].ID, liked)
+	assert.Equal(t, snippets[1].ID, popular)
+
+	snippets, err = store.Starred(1)
+	assert.NilError(t, err)
+	assert.Equal(t, len(snippets), 2)
+
+	snippets, err = store.MostStarred(time.Now().Add(-time.Hour), 10)
+	assert.NilError(t, err)
+	assert.Equal(t, len(snippets), 2)
+	assert.Equal(t, snippets[0].ID, popular)
+	assert.Equal(t, snippets[0].Stars, 2)
+	assert.Equal(t, snippets[1].ID, liked)
+
+	snippets, err = store.MostStarred(time.Now().Add(time.Hour), 10)
+	assert.NilError(t, err)
+	assert.Equal(t, len(snippets), 0)
+
+	assert.NilError(t, store.Unstar(popular, 2))
+	assert.NilError(t, store.Unstar(popular, 2))
+	snippet, err = store.Get(popular)
+	assert.NilError(t, err)
+	assert.Equal(t, snippet.Stars, 1)
+
+	assert.NilError(t, store.Delete(liked, 1))
+	snippets, err = store.Starred(2)
+	assert.NilError(t, err)
+	assert.Equal(t, len(snippets), 0)
+}
+
+func TestSnippetModelStars(t *testing.T) {
+	db := newTestDB(t)
+	users := UserModel{DB: db, Dialect: SQLite}
+	assert.NilError(t, users.Insert("Alice", "alice@example.com", "pa$$word"))
+	assert.NilError(t, users.Insert("Bob", "bob@example.com", "pa$$word"))
+
+	testStars(t, &SnippetModel{DB: db, Dialect: SQLite})
+}
+
+func TestMemorySnippetModelStars(t *testing.T) {
+	users := NewMemoryUserModel()
+	assert.NilError(t, users.Insert("Alice", "alice@example.com", "pa$$word"))
+	assert.NilError(t, users.Insert("Bob", "bob@example.com", "pa$$word"))
+
+	testStars(t, NewMemorySnippetModel(users))
+}
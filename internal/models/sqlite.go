@@ -0,0 +1,20 @@
+package models
+
+import "strings"
+
+// SQLiteDSN turns a database file path into a DSN for the sqlite driver with
+// the connection settings the models rely on: foreign keys, WAL with a busy
+// timeout for concurrent readers, and timestamps written in SQLite's own
+// text layout.
+func SQLiteDSN(path string) string {
+	path = strings.TrimPrefix(path, "//")
+
+	sep := "?"
+	if strings.Contains(path, "?") {
+		sep = "&"
+	}
+
+	return "file:" + path + sep +
+		"_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)" +
+		"&_time_format=sqlite&_txlock=immediate"
+}
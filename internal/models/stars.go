@@ -0,0 +1,151 @@
+package models
+
+import (
+	"slices"
+	"time"
+)
+
+// Star records that the user starred a live snippet. Starring a snippet twice
+// keeps the time of the first star, and starring one which does not exist or
+// has expired does nothing.
+func (s *SnippetModel) Star(id int, userID int) error {
+	stmt := `INSERT INTO snippet_stars (snippet_id, user_id, created)
+SELECT s.id, ?, ` + s.Dialect.Now() + ` FROM snippets s WHERE s.id = ? AND ` + s.live()
+
+	_, err := s.DB.Exec(s.Dialect.Rebind(s.Dialect.InsertOrIgnore(stmt)), userID, id)
+	return err
+}
+
+// Unstar removes the user's star from a snippet, if they had starred it.
+func (s *SnippetModel) Unstar(id int, userID int) error {
+	stmt := `DELETE FROM snippet_stars WHERE snippet_id = ? AND user_id = ?`
+
+	_, err := s.DB.Exec(s.Dialect.Rebind(stmt), id, userID)
+	return err
+}
+
+// HasStarred reports whether the user has starred the snippet.
+func (s *SnippetModel) HasStarred(id int, userID int) (bool, error) {
+	var n int
+	query := `SELECT COUNT(*) FROM snippet_stars WHERE snippet_id = ? AND user_id = ?`
+	err := s.DB.QueryRow(s.Dialect.Rebind(query), id, userID).Scan(&n)
+	return n > 0, err
+}
+
+// Starred returns the live snippets the user has starred, most recently
+// starred first. Snippets which have since been made private by their owner
+// are left out.
+func (s *SnippetModel) Starred(userID int) ([]Snippet, error) {
+	query := `SELECT ` + snippetColumns + ` FROM ` + snippetTables + `
+JOIN snippet_stars star ON star.snippet_id = s.id
+WHERE star.user_id = ? AND ` + s.live() + ` AND (s.visibility <> 'private' OR s.user_id = ?)
+ORDER BY star.created DESC, s.id DESC`
+
+	return s.query(query, userID, userID)
+}
+
+// MostStarred returns up to limit live public snippets which were starred
+// after since, the most starred in that time first and the newest first
+// among equals.
+func (s *SnippetModel) MostStarred(since time.Time, limit int) ([]Snippet, error) {
+	query := `SELECT ` + snippetColumns + ` FROM ` + snippetTables + `
+JOIN (SELECT snippet_id, COUNT(*) AS recent FROM snippet_stars WHERE created > ? GROUP BY snippet_id) r ON r.snippet_id = s.id
+WHERE ` + s.live() + listedOnly + ` ORDER BY r.recent DESC, s.created DESC, s.id DESC LIMIT ?`
+
+	return s.query(query, s.Dialect.Time(since), limit)
+}
+
+func (m *MemorySnippetModel) Star(id int, userID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	snippet, ok := m.snippets[id]
+	if !ok || !snippet.liveAt(time.Now()) {
+		return nil
+	}
+
+	if m.stars[id] == nil {
+		m.stars[id] = make(map[int]time.Time)
+	}
+	if _, ok := m.stars[id][userID]; !ok {
+		m.stars[id][userID] = time.Now().UTC()
+	}
+
+	return nil
+}
+
+func (m *MemorySnippetModel) Unstar(id int, userID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.stars[id], userID)
+
+	return nil
+}
+
+func (m *MemorySnippetModel) HasStarred(id int, userID int) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	_, ok := m.stars[id][userID]
+	return ok, nil
+}
+
+func (m *MemorySnippetModel) Starred(userID int) ([]Snippet, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	now := time.Now().UTC()
+	starred := make(map[int]time.Time)
+	var snippets []Snippet
+	for id, stars := range m.stars {
+		snippet, ok := m.snippets[id]
+		created, starredByUser := stars[userID]
+		if !ok || !starredByUser || !snippet.liveAt(now) || (snippet.Visibility == VisibilityPrivate && snippet.UserID != userID) {
+			continue
+		}
+		starred[id] = created
+		snippets = append(snippets, m.listing(snippet))
+	}
+
+	slices.SortFunc(snippets, func(a, b Snippet) int {
+		if c := starred[b.ID].Compare(starred[a.ID]); c != 0 {
+			return c
+		}
+		return b.ID - a.ID
+	})
+
+	return snippets, nil
+}
+
+func (m *MemorySnippetModel) MostStarred(since time.Time, limit int) ([]Snippet, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	now := time.Now().UTC()
+	recent := make(map[int]int)
+	var snippets []Snippet
+	for id, stars := range m.stars {
+		snippet, ok := m.snippets[id]
+		if !ok || !listed(snippet, now) {
+			continue
+		}
+		for _, created := range stars {
+			if created.After(since) {
+				recent[id]++
+			}
+		}
+		if recent[id] > 0 {
+			snippets = append(snippets, m.listing(snippet))
+		}
+	}
+
+	sortNewestFirst(snippets)
+	slices.SortStableFunc(snippets, func(a, b Snippet) int { return recent[b.ID] - recent[a.ID] })
+
+	if len(snippets) > limit {
+		snippets = snippets[:limit]
+	}
+
+	return snippets, nil
+}
@@ -0,0 +1,109 @@
+package models
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// MaxTags and MaxTagLength limit the tags a snippet can carry.
+const (
+	MaxTags      = 5
+	MaxTagLength = 30
+)
+
+// ParseTags splits a comma or space separated list of tags, lowercasing them
+// and dropping duplicates while keeping their order.
+func ParseTags(list string) []string {
+	var tags []string
+	for _, tag := range strings.FieldsFunc(strings.ToLower(list), func(r rune) bool { return r == ',' || unicode.IsSpace(r) }) {
+		if !slices.Contains(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// sortedTags returns a sorted copy of tags, the order in which the SQL model
+// loads them.
+func sortedTags(tags []string) []string {
+	tags = slices.Clone(tags)
+	slices.Sort(tags)
+	return tags
+}
+
+// setTags replaces the tags of a snippet, creating the tags nobody has used
+// yet.
+func setTags(db dbtx, d Dialect, snippetID int, tags []string) error {
+	_, err := db.Exec(d.Rebind(`DELETE FROM snippet_tags WHERE snippet_id = ?`), snippetID)
+	if err != nil {
+		return err
+	}
+
+	for _, tag := range tags {
+		_, err = db.Exec(d.Rebind(d.InsertOrIgnore(`INSERT INTO tags (name) VALUES (?)`)), tag)
+		if err != nil {
+			return err
+		}
+
+		var tagID int
+		err = db.QueryRow(d.Rebind(`SELECT id FROM tags WHERE name = ?`), tag).Scan(&tagID)
+		if err != nil {
+			return err
+		}
+
+		_, err = db.Exec(d.Rebind(`INSERT INTO snippet_tags (snippet_id, tag_id) VALUES (?, ?)`), snippetID, tagID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// loadTags fills in the tags of snippets, in alphabetical order, with a single
+// query.
+func loadTags(db dbtx, d Dialect, snippets []Snippet) error {
+	if len(snippets) == 0 {
+		return nil
+	}
+
+	index := make(map[int]int, len(snippets))
+	args := make([]any, len(snippets))
+	for i, snippet := range snippets {
+		index[snippet.ID] = i
+		args[i] = snippet.ID
+	}
+
+	query := `SELECT st.snippet_id, t.name FROM snippet_tags st JOIN tags t ON t.id = st.tag_id
+WHERE st.snippet_id IN (?` + strings.Repeat(`, ?`, len(snippets)-1) + `) ORDER BY t.name`
+
+	rows, err := db.Query(d.Rebind(query), args...)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var snippetID int
+		var name string
+		err = rows.Scan(&snippetID, &name)
+		if err != nil {
+			return err
+		}
+		i := index[snippetID]
+		snippets[i].Tags = append(snippets[i].Tags, name)
+	}
+
+	return rows.Err()
+}
+
+// Tagged returns the live public snippets carrying tag, newest first.
+func (s *SnippetModel) Tagged(tag string) ([]Snippet, error) {
+	query := `SELECT ` + snippetColumns + ` FROM ` + snippetTables + `
+JOIN snippet_tags st ON st.snippet_id = s.id JOIN tags t ON t.id = st.tag_id
+WHERE t.name = ? AND ` + s.live() + listedOnly + ` ORDER BY s.created DESC, s.id DESC`
+
+	return s.query(query, tag)
+}
@@ -0,0 +1,27 @@
+package models
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+)
+
+// newTestDB opens a throwaway SQLite database migrated to the latest schema, so the
+// SQL models can be tested without an external database server.
+func newTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	db, err := sql.Open(SQLite.DriverName(), SQLiteDSN(filepath.Join(t.TempDir(), "test.db")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	migrator := Migrator{DB: db, Dialect: SQLite}
+	_, err = migrator.Up()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
@@ -0,0 +1,136 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+// TokenPrefix starts every personal access token, which makes leaked tokens
+// easy to recognise.
+const TokenPrefix = "sbx_"
+
+// Token is a personal access token. Only a hash of the secret is stored, the
+// plaintext is shown to the user once when the token is created.
+type Token struct {
+	ID      int
+	UserID  int
+	Name    string
+	Created time.Time
+}
+
+// TokenStore is the storage-agnostic set of personal access token
+// operations the web application depends on.
+type TokenStore interface {
+	New(userID int, name string) (string, error)
+	ForUser(userID int) ([]Token, error)
+	Revoke(userID, id int) error
+	Authenticate(plaintext string) (int, error)
+}
+
+// generateToken returns a fresh plaintext token and the hash to store for it.
+func generateToken() (string, string, error) {
+	secret := make([]byte, 32)
+	_, err := rand.Read(secret)
+	if err != nil {
+		return "", "", err
+	}
+
+	plaintext := TokenPrefix + base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(secret)
+	return plaintext, hashToken(plaintext), nil
+}
+
+// hashToken hashes a plaintext token for storage and lookup. Tokens carry 256
+// bits of randomness, so a fast unsalted hash is enough here, unlike for
+// passwords.
+func hashToken(plaintext string) string {
+	sum := sha256.Sum256([]byte(plaintext))
+	return hex.EncodeToString(sum[:])
+}
+
+type TokenModel struct {
+	DB      *sql.DB
+	Dialect Dialect
+}
+
+func (m *TokenModel) New(userID int, name string) (string, error) {
+	plaintext, hash, err := generateToken()
+	if err != nil {
+		return "", err
+	}
+
+	stmt := `INSERT INTO tokens (user_id, name, hash, created) VALUES (?, ?, ?, ` + m.Dialect.Now() + `)`
+
+	_, err = m.DB.Exec(m.Dialect.Rebind(stmt), userID, name, hash)
+	if err != nil {
+		return "", err
+	}
+
+	return plaintext, nil
+}
+
+func (m *TokenModel) ForUser(userID int) ([]Token, error) {
+	stmt := `SELECT id, user_id, name, created FROM tokens WHERE user_id = ? ORDER BY created DESC, id DESC`
+
+	rows, err := m.DB.Query(m.Dialect.Rebind(stmt), userID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var tokens []Token
+
+	for rows.Next() {
+		var token Token
+		err = rows.Scan(&token.ID, &token.UserID, &token.Name, &token.Created)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, token)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+func (m *TokenModel) Revoke(userID, id int) error {
+	stmt := `DELETE FROM tokens WHERE id = ? AND user_id = ?`
+
+	rslt, err := m.DB.Exec(m.Dialect.Rebind(stmt), id, userID)
+	if err != nil {
+		return err
+	}
+
+	n, err := rslt.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+func (m *TokenModel) Authenticate(plaintext string) (int, error) {
+	stmt := `SELECT user_id FROM tokens WHERE hash = ?`
+
+	var userID int
+	err := m.DB.QueryRow(m.Dialect.Rebind(stmt), hashToken(plaintext)).Scan(&userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrInvalidToken
+		}
+		return 0, err
+	}
+
+	return userID, nil
+}
@@ -0,0 +1,41 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"vtorosyan.learning/internal/assert"
+)
+
+func TestTokenModel(t *testing.T) {
+	db := newTestDB(t)
+	users := UserModel{DB: db, Dialect: SQLite}
+	m := TokenModel{DB: db, Dialect: SQLite}
+
+	assert.NilError(t, users.Insert("Alice", "alice@example.com", "pa$$word"))
+	assert.NilError(t, users.Insert("Bob", "bob@example.com", "pa$$word"))
+
+	plaintext, err := m.New(1, "ci")
+	assert.NilError(t, err)
+	assert.Equal(t, strings.HasPrefix(plaintext, TokenPrefix), true)
+
+	userID, err := m.Authenticate(plaintext)
+	assert.NilError(t, err)
+	assert.Equal(t, userID, 1)
+
+	_, err = m.Authenticate(TokenPrefix + "nope")
+	assert.Equal(t, errors.Is(err, ErrInvalidToken), true)
+
+	tokens, err := m.ForUser(1)
+	assert.NilError(t, err)
+	assert.Equal(t, len(tokens), 1)
+	assert.Equal(t, tokens[0].Name, "ci")
+
+	err = m.Revoke(2, tokens[0].ID)
+	assert.Equal(t, errors.Is(err, ErrNoRecord), true)
+
+	assert.NilError(t, m.Revoke(1, tokens[0].ID))
+
+	_, err = m.Authenticate(plaintext)
+	assert.Equal(t, errors.Is(err, ErrInvalidToken), true)
+}
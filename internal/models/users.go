@@ -3,9 +3,7 @@ package models
 import (
 	"database/sql"
 	"errors"
-	"github.com/go-sql-driver/mysql"
 	"golang.org/x/crypto/bcrypt"
-	"strings"
 	"time"
 )
 
@@ -17,8 +15,17 @@ type Users struct {
 	Created        time.Time
 }
 
+// UserStore is the storage-agnostic set of user operations the web
+// application depends on.
+type UserStore interface {
+	Insert(name, email, password string) error
+	Authenticate(email, password string) (int, error)
+	Exists(id int) (bool, error)
+}
+
 type UserModel struct {
-	DB *sql.DB
+	DB      *sql.DB
+	Dialect Dialect
 }
 
 func (m *UserModel) Insert(name, email, password string) error {
@@ -27,17 +34,12 @@ func (m *UserModel) Insert(name, email, password string) error {
 		return err
 	}
 
-	stmt := `INSERT INTO snippetbox.users (name, email, hashed_password, created)
-	VALUES (?, ?, ?, UTC_TIMESTAMP())`
+	stmt := `INSERT INTO users (name, email, hashed_password, created)
+	VALUES (?, ?, ?, ` + m.Dialect.Now() + `)`
 
-	_, err = m.DB.Exec(stmt, name, email, string(hashedPassword))
-	if err != nil {
-		var mySQLErr *mysql.MySQLError
-		if errors.As(err, &mySQLErr) {
-			if mySQLErr.Number == 1062 && strings.Contains(mySQLErr.Message, "users_uc_email") {
-				return ErrDuplicateEmail
-			}
-		}
+	_, err = m.DB.Exec(m.Dialect.Rebind(stmt), name, email, string(hashedPassword))
+	if err != nil && m.Dialect.IsDuplicate(err, "users_uc_email") {
+		return ErrDuplicateEmail
 	}
 
 	return err
@@ -45,11 +47,11 @@ func (m *UserModel) Insert(name, email, password string) error {
 
 func (m *UserModel) Authenticate(email, password string) (int, error) {
 
-	stmt := `SELECT id, hashed_password FROM snippetbox.users WHERE email=?`
+	stmt := `SELECT id, hashed_password FROM users WHERE email=?`
 
 	var userId int
 	var hashedPassword string
-	err := m.DB.QueryRow(stmt, email).Scan(&userId, &hashedPassword)
+	err := m.DB.QueryRow(m.Dialect.Rebind(stmt), email).Scan(&userId, &hashedPassword)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return -1, ErrInvalidCredentials
@@ -70,8 +72,8 @@ func (m *UserModel) Authenticate(email, password string) (int, error) {
 func (m *UserModel) Exists(id int) (bool, error) {
 	var exists bool
 
-	stmt := `SELECT EXISTS(SELECT id FROM snippetbox.users WHERE id =?)`
+	stmt := `SELECT EXISTS(SELECT id FROM users WHERE id =?)`
 
-	err := m.DB.QueryRow(stmt, id).Scan(&exists)
+	err := m.DB.QueryRow(m.Dialect.Rebind(stmt), id).Scan(&exists)
 	return exists, err
 }
@@ -0,0 +1,32 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"vtorosyan.learning/internal/assert"
+)
+
+func TestUserModel(t *testing.T) {
+	m := UserModel{DB: newTestDB(t), Dialect: SQLite}
+
+	err := m.Insert("Alice", "alice@example.com", "pa$$word")
+	assert.NilError(t, err)
+
+	err = m.Insert("Alice Again", "alice@example.com", "pa$$word")
+	assert.Equal(t, errors.Is(err, ErrDuplicateEmail), true)
+
+	id, err := m.Authenticate("alice@example.com", "pa$$word")
+	assert.NilError(t, err)
+	assert.Equal(t, id, 1)
+
+	_, err = m.Authenticate("alice@example.com", "wrong")
+	assert.Equal(t, errors.Is(err, ErrInvalidCredentials), true)
+
+	exists, err := m.Exists(id)
+	assert.NilError(t, err)
+	assert.Equal(t, exists, true)
+
+	exists, err = m.Exists(42)
+	assert.NilError(t, err)
+	assert.Equal(t, exists, false)
+}
@@ -0,0 +1,75 @@
+package models
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"strconv"
+	"strings"
+)
+
+// Visibility says who can see a snippet.
+type Visibility string
+
+const (
+	// VisibilityPublic snippets are listed and can be viewed by anyone.
+	VisibilityPublic Visibility = "public"
+	// VisibilityUnlisted snippets are never listed and can only be viewed
+	// through their slug, by anyone who has been given the link.
+	VisibilityUnlisted Visibility = "unlisted"
+	// VisibilityPrivate snippets can only be viewed by their owner.
+	VisibilityPrivate Visibility = "private"
+)
+
+// listedOnly restricts a query over snippets s to the ones which may be
+// listed: public snippets without a view limit, as passers-by would use up
+// the views of those.
+const listedOnly = ` AND s.visibility = 'public' AND s.max_views IS NULL`
+
+// VisibleTo reports whether the user may view the snippet, having looked it
+// up by its slug or by its id. A userID of 0 is an anonymous visitor. Owners
+// can always view their own snippets.
+func (s Snippet) VisibleTo(userID int, bySlug bool) bool {
+	if s.UserID != 0 && s.UserID == userID {
+		return true
+	}
+
+	switch s.Visibility {
+	case VisibilityPrivate:
+		return false
+	case VisibilityUnlisted:
+		return bySlug
+	default:
+		return true
+	}
+}
+
+// Ref returns what identifies the snippet in its URLs: the slug for unlisted
+// snippets, the id for the rest.
+func (s Snippet) Ref() string {
+	if s.Visibility == VisibilityUnlisted && s.Slug != "" {
+		return s.Slug
+	}
+	return strconv.Itoa(s.ID)
+}
+
+// visibility defaults an unset visibility to VisibilityPublic.
+func (input SnippetInput) visibility() Visibility {
+	if input.Visibility == "" {
+		return VisibilityPublic
+	}
+	return input.Visibility
+}
+
+var slugEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// newSlug returns a random slug carrying 128 bits of randomness, so that
+// unlisted snippets cannot be found by guessing.
+func newSlug() (string, error) {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.ToLower(slugEncoding.EncodeToString(b)), nil
+}
@@ -14,6 +14,10 @@ type Validator struct {
 
 var EmailRegex = regexp.MustCompile("^[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\\.[a-zA-Z]{2,}")
 
+// TagRegex matches lowercase words of letters and digits joined by single
+// hyphens, which are safe to use as they are in URLs.
+var TagRegex = regexp.MustCompile("^[a-z0-9]+(-[a-z0-9]+)*$")
+
 func (v *Validator) Valid() bool {
 	return len(v.FieldErrors) == 0
 }
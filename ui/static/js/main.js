@@ -5,4 +5,183 @@ for (var i = 0; i < navLinks.length; i++) {
 		link.classList.add("live");
 		break;
 	}
-}
\ No newline at end of file
+}
+
+// The files of the snippet forms. Rows are added by copying the last one and
+// removed down to a single row, renumbering the fields so that the server
+// reads them in order.
+function renumberFiles(form) {
+	var rows = form.querySelectorAll("[data-file]");
+	for (var i = 0; i < rows.length; i++) {
+		var fields = rows[i].querySelectorAll("[name^='files[']");
+		for (var j = 0; j < fields.length; j++) {
+			fields[j].name = fields[j].name.replace(/^files\[\d+\]/, "files[" + i + "]");
+		}
+	}
+}
+
+var addFileButtons = document.querySelectorAll("[data-add-file]");
+for (var i = 0; i < addFileButtons.length; i++) {
+	addFileButtons[i].addEventListener("click", function (event) {
+		var form = event.target.form;
+		var rows = form.querySelectorAll("[data-file]");
+		var last = rows[rows.length - 1];
+		var row = last.cloneNode(true);
+		var errors = row.querySelectorAll("label.error");
+		for (var j = 0; j < errors.length; j++) {
+			errors[j].remove();
+		}
+		row.querySelector("input").value = "";
+		row.querySelector("select").value = "";
+		row.querySelector("textarea").value = "";
+		last.after(row);
+		renumberFiles(form);
+	});
+}
+
+document.addEventListener("click", function (event) {
+	if (!event.target.matches("[data-remove-file]")) {
+		return;
+	}
+	var form = event.target.form;
+	if (form.querySelectorAll("[data-file]").length > 1) {
+		event.target.closest("[data-file]").remove();
+		renumberFiles(form);
+	}
+});
+
+// Encrypted snippets. The browser encrypts the content with AES-GCM before
+// it is sent, under a random key which only ever travels in the fragment of
+// the snippet's link, so the server never sees it. The encrypted content is
+// "v1." followed by the base64 of the nonce and the ciphertext; the server
+// checks that format in validCiphertext.
+var cipherPrefix = "v1.";
+
+function toBase64(bytes) {
+	var binary = "";
+	for (var i = 0; i < bytes.length; i++) {
+		binary += String.fromCharCode(bytes[i]);
+	}
+	return btoa(binary);
+}
+
+function fromBase64(text) {
+	var binary = atob(text);
+	var bytes = new Uint8Array(binary.length);
+	for (var i = 0; i < binary.length; i++) {
+		bytes[i] = binary.charCodeAt(i);
+	}
+	return bytes;
+}
+
+function importKey(raw) {
+	return crypto.subtle.importKey("raw", raw, "AES-GCM", false, ["encrypt", "decrypt"]);
+}
+
+function encrypt(raw, plaintext) {
+	var nonce = crypto.getRandomValues(new Uint8Array(12));
+	return importKey(raw).then(function (key) {
+		return crypto.subtle.encrypt({name: "AES-GCM", iv: nonce}, key, new TextEncoder().encode(plaintext));
+	}).then(function (ciphertext) {
+		var data = new Uint8Array(nonce.length + ciphertext.byteLength);
+		data.set(nonce);
+		data.set(new Uint8Array(ciphertext), nonce.length);
+		return cipherPrefix + toBase64(data);
+	});
+}
+
+function decrypt(raw, content) {
+	if (content.indexOf(cipherPrefix) !== 0) {
+		return Promise.reject(new Error("not encrypted"));
+	}
+	var data = fromBase64(content.slice(cipherPrefix.length));
+	return importKey(raw).then(function (key) {
+		return crypto.subtle.decrypt({name: "AES-GCM", iv: data.slice(0, 12)}, key, data.slice(12));
+	}).then(function (plaintext) {
+		return new TextDecoder().decode(plaintext);
+	});
+}
+
+// The key is the 32 bytes in the fragment, as unpadded base64url.
+function keyToFragment(raw) {
+	return "#" + toBase64(raw).replace(/\+/g, "-").replace(/\//g, "_").replace(/=+$/, "");
+}
+
+function keyFromFragment() {
+	var text = window.location.hash.slice(1).replace(/-/g, "+").replace(/_/g, "/");
+	while (text.length % 4 !== 0) {
+		text += "=";
+	}
+	try {
+		var raw = fromBase64(text);
+		return raw.length === 32 ? raw : null;
+	} catch (e) {
+		return null;
+	}
+}
+
+// On the create page the content of every file is encrypted just before the
+// form is sent, under the same key, and the key goes in the fragment of the
+// form's action. Browsers keep the
+// fragment when they follow the redirect to the new snippet, which is how its
+// page gets the key.
+var encryptable = document.querySelector("form[data-encryptable]");
+if (encryptable) {
+	var encryptBox = encryptable.querySelector("input[name='encrypted']");
+	var contents = function () {
+		return Array.prototype.filter.call(encryptable.querySelectorAll("textarea"), function (textarea) {
+			return textarea.value !== "";
+		});
+	};
+
+	// A form sent back with errors holds the ciphertext, and the key is in
+	// the page's own fragment.
+	var formKey = keyFromFragment();
+	if (formKey && encryptBox.checked) {
+		contents().forEach(function (content) {
+			decrypt(formKey, content.value).then(function (plaintext) {
+				content.value = plaintext;
+			}, function () {});
+		});
+	}
+
+	encryptable.addEventListener("submit", function (event) {
+		var filled = contents();
+		if (!encryptBox.checked || filled.length === 0) {
+			return;
+		}
+		event.preventDefault();
+
+		var raw = crypto.getRandomValues(new Uint8Array(32));
+		Promise.all(filled.map(function (content) {
+			return encrypt(raw, content.value);
+		})).then(function (ciphertexts) {
+			filled.forEach(function (content, i) {
+				content.value = ciphertexts[i];
+			});
+			encryptable.action = encryptable.getAttribute("action").split("#")[0] + keyToFragment(raw);
+			encryptable.submit();
+		});
+	});
+}
+
+// The unlock and view confirmation forms carry the key along too.
+var keepFragment = document.querySelectorAll("form[data-keep-fragment]");
+for (var i = 0; i < keepFragment.length; i++) {
+	keepFragment[i].action = keepFragment[i].getAttribute("action") + window.location.hash;
+}
+
+var encrypted = document.querySelectorAll("pre.encrypted[data-ciphertext]");
+var viewKey = keyFromFragment();
+Array.prototype.forEach.call(encrypted, function (pre) {
+	if (!viewKey) {
+		pre.textContent = "The key to decrypt this snippet is missing from the link.";
+		return;
+	}
+	decrypt(viewKey, pre.getAttribute("data-ciphertext")).then(function (plaintext) {
+		pre.textContent = plaintext;
+		pre.classList.add("decrypted");
+	}, function () {
+		pre.textContent = "This snippet could not be decrypted with the key in the link.";
+	});
+});